@@ -2,11 +2,16 @@ package main
 
 import (
 	"context"
+	"encoding/csv"
+	"errors"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
@@ -15,6 +20,7 @@ import (
 	"github.com/redis/go-redis/v9"
 
 	"rate-limiter/internal/limitter"
+	"rate-limiter/middleware"
 )
 
 // Config holds application configuration
@@ -24,6 +30,46 @@ type Config struct {
 	RedisPassword string
 	RedisDB       int
 	Environment   string
+	AdminToken    string
+	// Connection limits per remote IP, 0 disables
+	ConnRateLimit int
+	MaxConnsPerIP int
+	// Fraction of the limit after which requests are rejected early, 0 disables
+	EarlyThrottle float64
+	// Fraction of the limit that may be leased locally, 0 disables hybrid mode
+	HybridMaxError float64
+	// Expected number of server instances sharing the limits
+	Instances int
+	// How long to gather limiter checks into one pipeline, 0 disables batching
+	BatchDelay time.Duration
+	// How long a rate limit decision may take before the fallback applies
+	LatencyBudget time.Duration
+	// Decision past the latency budget: "local" (default), "allow" or "deny"
+	BudgetFallback string
+	// How long denied clients are rejected without asking Redis again
+	DenialTTL time.Duration
+	// Local region name, enables active-active replication to PeerRedisAddrs
+	Region         string
+	PeerRedisAddrs []string
+	ReplicationLag time.Duration
+	// "redis" (default), "memory" for a single instance without Redis, or
+	// "peer" for peer-to-peer limiting between Peers
+	LimiterBackend string
+	Peers          []string
+	SelfURL        string
+	PeerToken      string
+	// File the memory and peer backends snapshot their state to, "" disables
+	SnapshotPath     string
+	SnapshotInterval time.Duration
+	// "sliding_log" (default), "gcra" or "token_bucket" for the API routes
+	Algorithm string
+	// Convert sliding logs to Algorithm on first access instead of resetting
+	DualRead bool
+	// Charge API keys against their prepaid credit balance on the API routes
+	CreditsMetered bool
+	// Hard limit of the API routes, above which the soft limit is served as
+	// billed overage, 0 disables overage
+	OverageHardLimit int
 }
 
 // RedisClient wraps redis operations and implements limiter.RedisClient
@@ -40,10 +86,18 @@ func (r *RedisClient) Set(ctx context.Context, key string, value interface{}, ex
 	return &StatusCmdWrapper{r.client.Set(ctx, key, value, expiration)}
 }
 
+func (r *RedisClient) SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) limitter.BoolCmd {
+	return &BoolCmdWrapper{r.client.SetNX(ctx, key, value, expiration)}
+}
+
 func (r *RedisClient) Incr(ctx context.Context, key string) limitter.IntCmd {
 	return &IntCmdWrapper{r.client.Incr(ctx, key)}
 }
 
+func (r *RedisClient) IncrBy(ctx context.Context, key string, value int64) limitter.IntCmd {
+	return &IntCmdWrapper{r.client.IncrBy(ctx, key, value)}
+}
+
 func (r *RedisClient) Expire(ctx context.Context, key string, expiration time.Duration) limitter.BoolCmd {
 	return &BoolCmdWrapper{r.client.Expire(ctx, key, expiration)}
 }
@@ -81,6 +135,14 @@ func (r *RedisClient) ZRemRangeByScore(ctx context.Context, key string, min, max
 	return &IntCmdWrapper{r.client.ZRemRangeByScore(ctx, key, min, max)}
 }
 
+func (r *RedisClient) ZRemRangeByRank(ctx context.Context, key string, start, stop int64) limitter.IntCmd {
+	return &IntCmdWrapper{r.client.ZRemRangeByRank(ctx, key, start, stop)}
+}
+
+func (r *RedisClient) ZRem(ctx context.Context, key string, members ...interface{}) limitter.IntCmd {
+	return &IntCmdWrapper{r.client.ZRem(ctx, key, members...)}
+}
+
 func (r *RedisClient) ZCard(ctx context.Context, key string) limitter.IntCmd {
 	return &IntCmdWrapper{r.client.ZCard(ctx, key)}
 }
@@ -95,6 +157,20 @@ func (r *RedisClient) ZRange(ctx context.Context, key string, start, stop int64,
 	return &StringSliceCmdWrapper{r.client.ZRange(ctx, key, start, stop)}
 }
 
+func (r *RedisClient) ZRevRange(ctx context.Context, key string, start, stop int64, args ...interface{}) limitter.StringSliceCmd {
+	// Handle the WITHSCORES option if present
+	if len(args) > 0 {
+		if str, ok := args[0].(string); ok && str == "WITHSCORES" {
+			return &ZSliceCmdToStringSliceWrapper{r.client.ZRevRangeWithScores(ctx, key, start, stop)}
+		}
+	}
+	return &StringSliceCmdWrapper{r.client.ZRevRange(ctx, key, start, stop)}
+}
+
+func (r *RedisClient) ZIncrBy(ctx context.Context, key string, increment float64, member string) limitter.FloatCmd {
+	return &FloatCmdWrapper{r.client.ZIncrBy(ctx, key, increment, member)}
+}
+
 // Fixed ZAdd method - use redis.Z instead of *redis.Z
 func (r *RedisClient) ZAdd(ctx context.Context, key string, score float64, member interface{}) limitter.IntCmd {
 	return &IntCmdWrapper{r.client.ZAdd(ctx, key, redis.Z{Score: score, Member: member})}
@@ -104,6 +180,42 @@ func (r *RedisClient) ZCount(ctx context.Context, key string, min, max string) l
 	return &IntCmdWrapper{r.client.ZCount(ctx, key, min, max)}
 }
 
+func (r *RedisClient) PFAdd(ctx context.Context, key string, els ...interface{}) limitter.IntCmd {
+	return &IntCmdWrapper{r.client.PFAdd(ctx, key, els...)}
+}
+
+func (r *RedisClient) PFCount(ctx context.Context, keys ...string) limitter.IntCmd {
+	return &IntCmdWrapper{r.client.PFCount(ctx, keys...)}
+}
+
+func (r *RedisClient) PFMerge(ctx context.Context, dest string, keys ...string) limitter.StatusCmd {
+	return &StatusCmdWrapper{r.client.PFMerge(ctx, dest, keys...)}
+}
+
+func (r *RedisClient) Eval(ctx context.Context, script string, keys []string, args ...interface{}) limitter.InterfaceCmd {
+	return &InterfaceCmdWrapper{r.client.Eval(ctx, script, keys, args...)}
+}
+
+func (r *RedisClient) HIncrBy(ctx context.Context, key, field string, incr int64) limitter.IntCmd {
+	return &IntCmdWrapper{r.client.HIncrBy(ctx, key, field, incr)}
+}
+
+func (r *RedisClient) HGetAll(ctx context.Context, key string) limitter.MapStringStringCmd {
+	return &MapStringStringCmdWrapper{r.client.HGetAll(ctx, key)}
+}
+
+func (r *RedisClient) HSet(ctx context.Context, key string, values ...interface{}) limitter.IntCmd {
+	return &IntCmdWrapper{r.client.HSet(ctx, key, values...)}
+}
+
+func (r *RedisClient) Scan(ctx context.Context, cursor uint64, match string, count int64) limitter.ScanCmd {
+	return &ScanCmdWrapper{r.client.Scan(ctx, cursor, match, count)}
+}
+
+func (r *RedisClient) Type(ctx context.Context, key string) limitter.StatusCmd {
+	return &StatusCmdWrapper{r.client.Type(ctx, key)}
+}
+
 // Helper method to convert ZRangeWithScores to StringSliceCmd
 func (r *RedisClient) convertZRangeWithScoresToStringSlice(ctx context.Context, key string, start, stop int64) *redis.StringSliceCmd {
 	// Get the ZRangeWithScores result
@@ -133,11 +245,12 @@ type StringCmdWrapper struct {
 }
 
 func (w *StringCmdWrapper) Result() (string, error) {
-	return w.cmd.Result()
+	val, err := w.cmd.Result()
+	return val, translateErr(err)
 }
 
 func (w *StringCmdWrapper) Err() error {
-	return w.cmd.Err()
+	return translateErr(w.cmd.Err())
 }
 
 func (w *StringCmdWrapper) Val() string {
@@ -208,6 +321,22 @@ func (w *DurationCmdWrapper) Val() time.Duration {
 	return w.cmd.Val()
 }
 
+type FloatCmdWrapper struct {
+	cmd *redis.FloatCmd
+}
+
+func (w *FloatCmdWrapper) Result() (float64, error) {
+	return w.cmd.Result()
+}
+
+func (w *FloatCmdWrapper) Err() error {
+	return w.cmd.Err()
+}
+
+func (w *FloatCmdWrapper) Val() float64 {
+	return w.cmd.Val()
+}
+
 type StringSliceCmdWrapper struct {
 	cmd *redis.StringSliceCmd
 }
@@ -224,10 +353,59 @@ func (w *StringSliceCmdWrapper) Val() []string {
 	return w.cmd.Val()
 }
 
+type MapStringStringCmdWrapper struct {
+	cmd *redis.MapStringStringCmd
+}
+
+func (w *MapStringStringCmdWrapper) Result() (map[string]string, error) {
+	return w.cmd.Result()
+}
+
+func (w *MapStringStringCmdWrapper) Err() error {
+	return w.cmd.Err()
+}
+
+func (w *MapStringStringCmdWrapper) Val() map[string]string {
+	return w.cmd.Val()
+}
+
+type ScanCmdWrapper struct {
+	cmd *redis.ScanCmd
+}
+
+func (w *ScanCmdWrapper) Result() ([]string, uint64, error) {
+	return w.cmd.Result()
+}
+
+func (w *ScanCmdWrapper) Err() error {
+	return w.cmd.Err()
+}
+
+type InterfaceCmdWrapper struct {
+	cmd *redis.Cmd
+}
+
+func (w *InterfaceCmdWrapper) Result() (interface{}, error) {
+	val, err := w.cmd.Result()
+	return val, translateErr(err)
+}
+
+func (w *InterfaceCmdWrapper) Err() error {
+	return translateErr(w.cmd.Err())
+}
+
+func (w *InterfaceCmdWrapper) Val() interface{} {
+	return w.cmd.Val()
+}
+
 type PipelineWrapper struct {
 	pipe redis.Pipeliner
 }
 
+func (p *PipelineWrapper) Eval(ctx context.Context, script string, keys []string, args ...interface{}) limitter.InterfaceCmd {
+	return &InterfaceCmdWrapper{p.pipe.Eval(ctx, script, keys, args...)}
+}
+
 func (p *PipelineWrapper) ZRemRangeByScore(ctx context.Context, key string, min, max string) limitter.IntCmd {
 	return &IntCmdWrapper{p.pipe.ZRemRangeByScore(ctx, key, min, max)}
 }
@@ -273,6 +451,18 @@ func (p *PipelineWrapper) ZAdd(ctx context.Context, key string, score float64, m
 	return &IntCmdWrapper{p.pipe.ZAdd(ctx, key, redis.Z{Score: score, Member: member})}
 }
 
+func (p *PipelineWrapper) ZIncrBy(ctx context.Context, key string, increment float64, member string) limitter.FloatCmd {
+	return &FloatCmdWrapper{p.pipe.ZIncrBy(ctx, key, increment, member)}
+}
+
+func (p *PipelineWrapper) IncrBy(ctx context.Context, key string, value int64) limitter.IntCmd {
+	return &IntCmdWrapper{p.pipe.IncrBy(ctx, key, value)}
+}
+
+func (p *PipelineWrapper) Expire(ctx context.Context, key string, expiration time.Duration) limitter.BoolCmd {
+	return &BoolCmdWrapper{p.pipe.Expire(ctx, key, expiration)}
+}
+
 // New wrapper for ZSliceCmd to StringSliceCmd conversion
 type ZSliceCmdToStringSliceWrapper struct {
 	cmd *redis.ZSliceCmd
@@ -305,6 +495,14 @@ func (w *ZSliceCmdToStringSliceWrapper) Val() []string {
 	return result
 }
 
+// translateErr maps redis.Nil to limitter.Nil so the limiter doesn't depend on go-redis
+func translateErr(err error) error {
+	if err == redis.Nil {
+		return limitter.Nil
+	}
+	return err
+}
+
 type CmdWrapper struct {
 	cmd redis.Cmder
 }
@@ -321,16 +519,69 @@ func LoadConfig() *Config {
 	}
 
 	config := &Config{
-		ServerPort:    getEnv("SERVER_PORT", "8081"),
-		RedisAddr:     getEnv("REDIS_ADDR", "localhost:6379"),
-		RedisPassword: getEnv("REDIS_PASSWORD", ""),
-		RedisDB:       0,
-		Environment:   getEnv("ENVIRONMENT", "development"),
+		ServerPort:       getEnv("SERVER_PORT", "8081"),
+		RedisAddr:        getEnv("REDIS_ADDR", "localhost:6379"),
+		RedisPassword:    getEnv("REDIS_PASSWORD", ""),
+		RedisDB:          0,
+		Environment:      getEnv("ENVIRONMENT", "development"),
+		AdminToken:       getEnv("ADMIN_TOKEN", ""),
+		ConnRateLimit:    getIntEnv("CONN_RATE_LIMIT", 600),
+		MaxConnsPerIP:    getIntEnv("MAX_CONNS_PER_IP", 100),
+		EarlyThrottle:    getFloatEnv("EARLY_THROTTLE_THRESHOLD", 0),
+		HybridMaxError:   getFloatEnv("HYBRID_MAX_ERROR", 0),
+		Instances:        getIntEnv("INSTANCE_COUNT", 1),
+		BatchDelay:       getDurationEnv("BATCH_DELAY", 0),
+		LatencyBudget:    getDurationEnv("RATE_LIMIT_BUDGET", 5*time.Second),
+		BudgetFallback:   getEnv("RATE_LIMIT_BUDGET_FALLBACK", "local"),
+		DenialTTL:        getDurationEnv("DENIAL_CACHE_TTL", limitter.DefaultDenialTTL),
+		Region:           getEnv("REGION", ""),
+		PeerRedisAddrs:   getListEnv("PEER_REDIS_ADDRS"),
+		ReplicationLag:   getDurationEnv("REPLICATION_LAG", 100*time.Millisecond),
+		LimiterBackend:   getEnv("LIMITER_BACKEND", "redis"),
+		Peers:            getListEnv("PEERS"),
+		SelfURL:          getEnv("SELF_URL", ""),
+		PeerToken:        getEnv("PEER_TOKEN", ""),
+		SnapshotPath:     getEnv("SNAPSHOT_PATH", ""),
+		SnapshotInterval: getDurationEnv("SNAPSHOT_INTERVAL", 30*time.Second),
+		Algorithm:        getEnv("RATE_LIMIT_ALGORITHM", string(limitter.AlgorithmSlidingLog)),
+		DualRead:         getEnv("ALGORITHM_DUAL_READ", "true") == "true",
+		CreditsMetered:   getEnv("CREDITS_METERED", "false") == "true",
+		OverageHardLimit: getIntEnv("OVERAGE_HARD_LIMIT", 0),
 	}
 
 	return config
 }
 
+// validateLimiter rejects settings that each pick the limiter of the API
+// routes, since only one of them could take effect
+func validateLimiter(config *Config) error {
+	var choices []string
+	if config.Algorithm != string(limitter.AlgorithmSlidingLog) {
+		choices = append(choices, "RATE_LIMIT_ALGORITHM="+config.Algorithm)
+	}
+	if config.BatchDelay > 0 {
+		choices = append(choices, "BATCH_DELAY")
+	}
+	if config.Region != "" {
+		choices = append(choices, "REGION")
+	}
+	if config.HybridMaxError > 0 {
+		choices = append(choices, "HYBRID_MAX_ERROR")
+	}
+	switch config.LimiterBackend {
+	case "redis":
+	case "memory", "peer":
+		choices = append(choices, "LIMITER_BACKEND="+config.LimiterBackend)
+	default:
+		return fmt.Errorf("unknown LIMITER_BACKEND %q", config.LimiterBackend)
+	}
+
+	if len(choices) > 1 {
+		return fmt.Errorf("%s can't be combined, each replaces the limiter of the API routes", strings.Join(choices, ", "))
+	}
+	return nil
+}
+
 // getEnv gets environment variable with fallback
 func getEnv(key, fallback string) string {
 	if value := os.Getenv(key); value != "" {
@@ -339,6 +590,50 @@ func getEnv(key, fallback string) string {
 	return fallback
 }
 
+// getIntEnv gets integer environment variable with fallback
+func getIntEnv(key string, fallback int) int {
+	if value := os.Getenv(key); value != "" {
+		if intValue, err := strconv.Atoi(value); err == nil {
+			return intValue
+		}
+		log.Printf("Invalid integer value for %s: %s, using default: %d", key, value, fallback)
+	}
+	return fallback
+}
+
+// getFloatEnv gets float environment variable with fallback
+func getFloatEnv(key string, fallback float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if floatValue, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatValue
+		}
+		log.Printf("Invalid float value for %s: %s, using default: %g", key, value, fallback)
+	}
+	return fallback
+}
+
+// getDurationEnv gets duration environment variable with fallback
+func getDurationEnv(key string, fallback time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if durationValue, err := time.ParseDuration(value); err == nil {
+			return durationValue
+		}
+		log.Printf("Invalid duration value for %s: %s, using default: %s", key, value, fallback)
+	}
+	return fallback
+}
+
+// getListEnv gets a comma-separated environment variable
+func getListEnv(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
 // NewRedisClient creates a new Redis client
 func NewRedisClient(config *Config) *RedisClient {
 	rdb := redis.NewClient(&redis.Options{
@@ -352,7 +647,12 @@ func NewRedisClient(config *Config) *RedisClient {
 	defer cancel()
 
 	if err := rdb.Ping(ctx).Err(); err != nil {
-		log.Fatalf("Failed to connect to Redis: %v", err)
+		if config.LimiterBackend == "redis" {
+			log.Fatalf("Failed to connect to Redis: %v", err)
+		}
+		// The memory and peer backends work without Redis
+		log.Printf("Redis unavailable, bans, credits and reports are disabled: %v", err)
+		return &RedisClient{client: rdb}
 	}
 
 	log.Println("Successfully connected to Redis")
@@ -389,21 +689,170 @@ func (r *RateLimiterAdapter) Allow(ctx context.Context, key string, limit int, w
 		return false, 0, time.Time{}, err
 	}
 	
+	return result.Allowed, result.Remaining, result.ResetTime, earlyErr(result)
+}
+
+// AllowN implements middleware.CostLimiter, falling back to a single unit
+// when the underlying limiter has no cost support
+func (r *RateLimiterAdapter) AllowN(ctx context.Context, key string, limit int, window time.Duration, n int) (bool, int, time.Time, error) {
+	costLimiter, ok := r.limiter.(limitter.CostLimiter)
+	if !ok {
+		return r.Allow(ctx, key, limit, window)
+	}
+	
+	result, err := costLimiter.IsAllowedN(ctx, key, limit, window, n)
+	if err != nil {
+		return false, 0, time.Time{}, err
+	}
+	
+	return result.Allowed, result.Remaining, result.ResetTime, earlyErr(result)
+}
+
+// Adjust implements middleware.CostLimiter
+func (r *RateLimiterAdapter) Adjust(ctx context.Context, key string, window time.Duration, delta int) error {
+	costLimiter, ok := r.limiter.(limitter.CostLimiter)
+	if !ok {
+		return limitter.ErrAdjustUnsupported
+	}
+	return costLimiter.Adjust(ctx, key, window, delta)
+}
+
+// Reserve implements middleware.Reserver
+func (r *RateLimiterAdapter) Reserve(ctx context.Context, key string, limit int, window time.Duration, n int) (bool, int, time.Time, limitter.Reservation, error) {
+	var result *limitter.RateLimitResult
+	var err error
+	if costLimiter, ok := r.limiter.(limitter.CostLimiter); ok && n != 1 {
+		result, err = costLimiter.IsAllowedN(ctx, key, limit, window, n)
+	} else {
+		result, err = r.limiter.IsAllowed(ctx, key, limit, window)
+	}
+	if err != nil {
+		return false, 0, time.Time{}, nil, err
+	}
+	
+	return result.Allowed, result.Remaining, result.ResetTime, result.Reservation, earlyErr(result)
+}
+
+// earlyErr reports early rejections to the middleware as
+// middleware.ErrRejectedEarly
+func earlyErr(result *limitter.RateLimitResult) error {
+	if result.Early {
+		return middleware.ErrRejectedEarly
+	}
+	return nil
+}
+
+// Peek implements middleware.Peeker when the underlying limiter supports it,
+// and returns limitter.ErrPeekUnsupported otherwise
+func (r *RateLimiterAdapter) Peek(ctx context.Context, key string, limit int, window time.Duration) (bool, int, time.Time, error) {
+	peeker, ok := r.limiter.(limitter.Peeker)
+	if !ok {
+		return false, 0, time.Time{}, limitter.ErrPeekUnsupported
+	}
+	
+	result, err := peeker.Peek(ctx, key, limit, window)
+	if err != nil {
+		return false, 0, time.Time{}, err
+	}
+	
 	return result.Allowed, result.Remaining, result.ResetTime, nil
 }
 
+// Services holds the limiter components shared by routes and middleware
+type Services struct {
+	Limiter      limitter.RateLimiter
+	Bans         *limitter.BanManager
+	HeavyHitters *limitter.HeavyHitterTracker
+	Credits      *limitter.CreditStore
+	// MeterCredits charges API keys against Credits on the API routes
+	MeterCredits bool
+	Overage      *limitter.OverageRecorder
+	Denials      *limitter.DenialCache
+	Fallback     *limitter.FallbackRateLimiter
+	Budget       *middleware.BudgetStats
+	// Memory holds the state of the memory and peer backends (nil otherwise)
+	Memory       *limitter.MemoryRateLimiter
+	SnapshotPath string
+	// State exports and imports the limiter state of the active backend
+	State limitter.StateStore
+	// LatencyBudget bounds each rate limit decision
+	LatencyBudget time.Duration
+	// BudgetFallback is the decision past the latency budget
+	BudgetFallback middleware.BudgetFallback
+	// Migration converts sliding logs to RATE_LIMIT_ALGORITHM (nil if unset)
+	Migration *limitter.MigratingRateLimiter
+	// OverageLimit is the hard limit of the API routes when requests above
+	// apiLimit are served as overage (0 disables overage)
+	OverageLimit int
+}
+
+// defaultPolicy names the rate limit policy applied to the API routes
+const defaultPolicy = "api_v1"
+
+// apiLimit is the number of requests per minute allowed on the API routes
+const apiLimit = 10
+
 // Create a Gin-compatible rate limit middleware
-func rateLimitMiddleware(limiterAdapter *RateLimiterAdapter) gin.HandlerFunc {
+func rateLimitMiddleware(limiterAdapter *RateLimiterAdapter, services *Services) gin.HandlerFunc {
+	bans := services.Bans
 	return func(c *gin.Context) {
 		// Create rate limit key based on client IP
 		clientIP := c.ClientIP()
 		key := fmt.Sprintf("rate_limit:ip:%s", clientIP)
+		banKey := fmt.Sprintf("ip:%s", clientIP)
+		
+		// Reject banned clients before touching the limiter
+		if bans != nil {
+			banCtx, cancelBan := sideEffectContext(c)
+			ban, err := bans.CheckBan(banCtx, banKey)
+			cancelBan()
+			if err != nil {
+				log.Printf("Ban check error: %v", err)
+			} else if ban != nil {
+				banResponse(c, ban)
+				return
+			}
+		}
 		
-		// Check rate limit (10 requests per minute)
-		ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+		// Clients known to be over the limit are rejected without a Redis call
+		if until, denied := services.Denials.Denied(key); denied {
+			c.Header("X-RateLimit-Limit", strconv.Itoa(apiLimit))
+			c.Header("X-RateLimit-Remaining", "0")
+			c.Header("X-RateLimit-Reset", fmt.Sprintf("%d", until.Unix()))
+			c.Header("Retry-After", fmt.Sprintf("%d", int64(time.Until(until).Seconds())))
+			JSONError(c, http.StatusTooManyRequests, "Rate limit exceeded")
+			c.Abort()
+			return
+		}
+		
+		// With overage, requests above the soft limit are served up to the hard limit
+		limit := apiLimit
+		if services.OverageLimit > apiLimit {
+			limit = services.OverageLimit
+		}
+		
+		// Check rate limit (requests per minute) within the latency budget.
+		// Past the budget the fallback limiter answers from its local estimate,
+		// unless another decision is configured.
+		start := time.Now()
+		ctx, cancel := context.WithTimeout(c.Request.Context(), services.LatencyBudget)
 		defer cancel()
+		allowed, remaining, resetTime, reservation, err := limiterAdapter.Reserve(ctx, key, limit, time.Minute, 1)
+		elapsed := time.Since(start)
+		overrun := elapsed > services.LatencyBudget || ctx.Err() != nil
+		services.Budget.Record(elapsed, overrun)
 		
-		allowed, remaining, resetTime, err := limiterAdapter.Allow(ctx, key, 10, time.Minute)
+		// Early rejections are not violations and don't exhaust the key
+		early := errors.Is(err, middleware.ErrRejectedEarly)
+		if early {
+			allowed, err = false, nil
+		}
+		if err != nil && overrun && services.BudgetFallback == middleware.BudgetDeny {
+			c.Header("Retry-After", "1")
+			JSONError(c, http.StatusTooManyRequests, "Rate limit exceeded")
+			c.Abort()
+			return
+		}
 		if err != nil {
 			// Log error but don't block request
 			log.Printf("Rate limit error: %v", err)
@@ -411,28 +860,393 @@ func rateLimitMiddleware(limiterAdapter *RateLimiterAdapter) gin.HandlerFunc {
 			return
 		}
 		
+		// Track the heaviest clients
+		if services.HeavyHitters != nil {
+			recordCtx, cancelRecord := sideEffectContext(c)
+			err := services.HeavyHitters.Record(recordCtx, defaultPolicy, banKey, 1)
+			cancelRecord()
+			if err != nil {
+				log.Printf("Heavy hitter tracking error: %v", err)
+			}
+		}
+		
+		// Requests above the soft limit are served as overage and recorded for billing
+		exhausted := remaining == 0 && !early
+		if limit > apiLimit {
+			used := limit - remaining
+			remaining = max(apiLimit-used, 0)
+			if allowed && used > apiLimit {
+				c.Header("X-RateLimit-Overage", strconv.Itoa(used-apiLimit))
+				if services.Overage != nil {
+					recordCtx, cancelRecord := sideEffectContext(c)
+					err := services.Overage.Record(recordCtx, defaultPolicy, banKey, 1)
+					cancelRecord()
+					if err != nil {
+						log.Printf("Overage recording error: %v", err)
+					}
+				}
+			}
+			c.Header("X-RateLimit-Hard-Limit", strconv.Itoa(limit))
+		}
+		
 		// Set rate limit headers
-		c.Header("X-RateLimit-Limit", "10")
+		c.Header("X-RateLimit-Limit", strconv.Itoa(apiLimit))
 		c.Header("X-RateLimit-Remaining", fmt.Sprintf("%d", remaining))
 		c.Header("X-RateLimit-Reset", fmt.Sprintf("%d", resetTime.Unix()))
 		
 		if !allowed {
+			// Repeated violations escalate to a temporary ban
+			if bans != nil && !early {
+				banCtx, cancelBan := sideEffectContext(c)
+				ban, err := bans.RecordViolation(banCtx, banKey)
+				cancelBan()
+				if err != nil {
+					log.Printf("Ban violation error: %v", err)
+				} else if ban != nil {
+					banResponse(c, ban)
+					return
+				}
+			}
+			
 			// Rate limit exceeded
 			c.Header("Retry-After", fmt.Sprintf("%d", int64(time.Until(resetTime).Seconds())))
+			if exhausted {
+				services.Denials.Deny(key, resetTime)
+			}
 			JSONError(c, http.StatusTooManyRequests, "Rate limit exceeded")
 			c.Abort()
 			return
 		}
 		
-		// Request is allowed, proceed
+		// Request is allowed, proceed. Clients don't lose quota when we fail.
+		if reservation == nil {
+			c.Next()
+			return
+		}
+		settleCtx := context.WithoutCancel(c.Request.Context())
+		defer func() {
+			if p := recover(); p != nil {
+				reservation.Refund(settleCtx)
+				panic(p)
+			}
+		}()
+		
 		c.Next()
+		
+		if c.Writer.Status() >= http.StatusInternalServerError {
+			reservation.Refund(settleCtx)
+		} else {
+			reservation.Commit(settleCtx)
+		}
+	}
+}
+
+// sideEffectTimeout bounds each Redis call the middleware makes besides the
+// limiter decision
+const sideEffectTimeout = time.Second
+
+// sideEffectContext returns the context of one Redis call made besides the
+// limiter decision, which is not part of the latency budget
+func sideEffectContext(c *gin.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithoutCancel(c.Request.Context()), sideEffectTimeout)
+}
+
+// banResponse rejects a request from a temporarily banned client
+func banResponse(c *gin.Context, ban *limitter.BanRecord) {
+	c.Header("Retry-After", fmt.Sprintf("%d", int64(time.Until(ban.ExpiresAt).Seconds())))
+	c.Header("X-RateLimit-Ban-Expires", fmt.Sprintf("%d", ban.ExpiresAt.Unix()))
+	c.JSON(http.StatusForbidden, gin.H{
+		"status":      http.StatusForbidden,
+		"error":       "Temporarily banned due to repeated rate limit violations",
+		"ban_expires": ban.ExpiresAt.UTC().Format(time.RFC3339),
+		"offense":     ban.Offense,
+		"timestamp":   time.Now().Unix(),
+	})
+	c.Abort()
+}
+
+// creditMiddleware charges requests carrying an API key against its prepaid
+// credit balance. Requests without an API key are not charged.
+func creditMiddleware(credits *limitter.CreditStore) gin.HandlerFunc {
+	charge := middleware.CreditMiddleware(credits, middleware.CreditConfig{
+		KeyFunc: func(r *http.Request) string {
+			if key := middleware.APIKeyFunc(r); strings.HasPrefix(key, "api_key:") {
+				return key
+			}
+			return ""
+		},
+	})
+
+	return func(c *gin.Context) {
+		served := false
+		charge(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			served = true
+			c.Request = r
+			c.Next()
+		})).ServeHTTP(c.Writer, c.Request)
+		if !served {
+			c.Abort()
+		}
+	}
+}
+
+// adminAuthMiddleware protects admin routes with a static token
+func adminAuthMiddleware(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token == "" || c.GetHeader("X-Admin-Token") != token {
+			JSONError(c, http.StatusForbidden, "Admin access denied")
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// requireService rejects requests to a component that is not running
+func requireService(running bool, name string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !running {
+			JSONError(c, http.StatusServiceUnavailable, "Redis is required for "+name)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// setupAdminRoutes sets up the administrative endpoints
+func setupAdminRoutes(router *gin.Engine, config *Config, services *Services) {
+	bans := services.Bans
+	admin := router.Group("/admin")
+	admin.Use(adminAuthMiddleware(config.AdminToken))
+	{
+		// Inspect an active ban
+		admin.GET("/bans/:key", requireService(bans != nil, "bans"), func(c *gin.Context) {
+			ban, err := bans.CheckBan(c.Request.Context(), c.Param("key"))
+			if err != nil {
+				JSONError(c, http.StatusInternalServerError, err.Error())
+				return
+			}
+			if ban == nil {
+				JSONError(c, http.StatusNotFound, "No active ban")
+				return
+			}
+			JSONResponse(c, http.StatusOK, gin.H{
+				"key":         ban.Key,
+				"offense":     ban.Offense,
+				"ban_expires": ban.ExpiresAt.UTC().Format(time.RFC3339),
+			})
+		})
+
+		// List the heaviest clients of a policy over recent intervals
+		admin.GET("/top", requireService(services.HeavyHitters != nil, "heavy-hitter reports"), func(c *gin.Context) {
+			policy := c.DefaultQuery("policy", defaultPolicy)
+			n, _ := strconv.Atoi(c.DefaultQuery("n", "10"))
+			intervals, _ := strconv.Atoi(c.DefaultQuery("intervals", "5"))
+			
+			top, err := services.HeavyHitters.Top(c.Request.Context(), policy, n, intervals)
+			if err != nil {
+				JSONError(c, http.StatusInternalServerError, err.Error())
+				return
+			}
+			JSONResponse(c, http.StatusOK, gin.H{
+				"policy":    policy,
+				"intervals": intervals,
+				"keys":      top,
+			})
+		})
+
+		// Show a prepaid credit balance
+		admin.GET("/credits/:key", requireService(services.Credits != nil, "credits"), func(c *gin.Context) {
+			balance, err := services.Credits.Balance(c.Request.Context(), c.Param("key"))
+			if err != nil {
+				JSONError(c, http.StatusInternalServerError, err.Error())
+				return
+			}
+			JSONResponse(c, http.StatusOK, gin.H{
+				"key":     c.Param("key"),
+				"balance": balance,
+			})
+		})
+
+		// Top up a prepaid credit balance
+		admin.POST("/credits/:key", requireService(services.Credits != nil, "credits"), func(c *gin.Context) {
+			var req struct {
+				Amount int64 `json:"amount"`
+			}
+			if err := c.ShouldBindJSON(&req); err != nil || req.Amount <= 0 {
+				JSONError(c, http.StatusBadRequest, "amount must be a positive integer")
+				return
+			}
+			
+			balance, err := services.Credits.TopUp(c.Request.Context(), c.Param("key"), req.Amount)
+			if err != nil {
+				JSONError(c, http.StatusInternalServerError, err.Error())
+				return
+			}
+			JSONResponse(c, http.StatusOK, gin.H{
+				"key":     c.Param("key"),
+				"added":   req.Amount,
+				"balance": balance,
+			})
+		})
+
+		// Export overage recorded above soft limits for billing
+		admin.GET("/overage", requireService(services.Overage != nil, "overage"), func(c *gin.Context) {
+			policy := c.DefaultQuery("policy", defaultPolicy)
+			period := c.DefaultQuery("period", services.Overage.Period(time.Now()))
+			
+			records, err := services.Overage.Export(c.Request.Context(), policy, period)
+			if err != nil {
+				JSONError(c, http.StatusInternalServerError, err.Error())
+				return
+			}
+			
+			if c.Query("format") == "csv" {
+				c.Header("Content-Type", "text/csv")
+				c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=overage-%s-%s.csv", policy, period))
+				w := csv.NewWriter(c.Writer)
+				w.Write([]string{"policy", "period", "key", "count"})
+				for _, rec := range records {
+					w.Write([]string{rec.Policy, rec.Period, rec.Key, strconv.FormatInt(rec.Count, 10)})
+				}
+				w.Flush()
+				return
+			}
+			
+			JSONResponse(c, http.StatusOK, gin.H{
+				"policy":  policy,
+				"period":  period,
+				"records": records,
+			})
+		})
+
+		// Lift a ban
+		admin.DELETE("/bans/:key", requireService(bans != nil, "bans"), func(c *gin.Context) {
+			if err := bans.Unban(c.Request.Context(), c.Param("key")); err != nil {
+				JSONError(c, http.StatusInternalServerError, err.Error())
+				return
+			}
+			services.Denials.Invalidate("rate_limit:" + c.Param("key"))
+			JSONResponse(c, http.StatusOK, gin.H{
+				"message": "Ban lifted",
+				"key":     c.Param("key"),
+			})
+		})
+
+		// Report whether decisions currently come from Redis or the local fallback
+		admin.GET("/fallback", func(c *gin.Context) {
+			JSONResponse(c, http.StatusOK, services.Fallback.Stats())
+		})
+
+		// Report rate limit decisions that exceeded the latency budget
+		admin.GET("/budget", func(c *gin.Context) {
+			JSONResponse(c, http.StatusOK, services.Budget.Report())
+		})
+
+		// Save the in-memory limiter state now
+		admin.POST("/snapshot", func(c *gin.Context) {
+			if services.Memory == nil || services.SnapshotPath == "" {
+				JSONError(c, http.StatusNotImplemented, "Snapshots need the memory or peer backend and SNAPSHOT_PATH")
+				return
+			}
+			if err := services.Memory.SaveSnapshot(services.SnapshotPath); err != nil {
+				JSONError(c, http.StatusInternalServerError, err.Error())
+				return
+			}
+			JSONResponse(c, http.StatusOK, gin.H{
+				"message": "Snapshot saved",
+				"path":    services.SnapshotPath,
+			})
+		})
+
+		// Export the state of every key under a prefix as NDJSON
+		admin.GET("/state", func(c *gin.Context) {
+			c.Header("Content-Type", "application/x-ndjson")
+			c.Status(http.StatusOK)
+			if _, err := limitter.ExportStates(c.Request.Context(), services.State, c.DefaultQuery("prefix", "rate_limit:"), c.Writer); err != nil {
+				// Headers are already sent, so the error can only be logged
+				log.Printf("State export error: %v", err)
+			}
+		})
+
+		// Import NDJSON state written by GET /admin/state
+		admin.POST("/state", func(c *gin.Context) {
+			imported, skipped, err := limitter.ImportStates(c.Request.Context(), services.State, c.Request.Body)
+			if err != nil {
+				JSONError(c, http.StatusBadRequest, fmt.Sprintf("imported %d keys before failing: %v", imported, err))
+				return
+			}
+			JSONResponse(c, http.StatusOK, gin.H{
+				"imported": imported,
+				"skipped":  skipped,
+			})
+		})
+
+		// Report how many sliding logs have been converted to the new algorithm
+		admin.GET("/migration", func(c *gin.Context) {
+			if services.Migration == nil {
+				JSONError(c, http.StatusNotImplemented, "No migration configured, set RATE_LIMIT_ALGORITHM")
+				return
+			}
+			report, err := services.Migration.Progress(c.Request.Context(), c.DefaultQuery("prefix", "rate_limit:ip:"))
+			if err != nil {
+				JSONError(c, http.StatusInternalServerError, err.Error())
+				return
+			}
+			JSONResponse(c, http.StatusOK, report)
+		})
+
+		// Convert every sliding log under a prefix now, using the policy's
+		// limit and window (10 per minute by default)
+		admin.POST("/migration", func(c *gin.Context) {
+			if services.Migration == nil {
+				JSONError(c, http.StatusNotImplemented, "No migration configured, set RATE_LIMIT_ALGORITHM")
+				return
+			}
+			limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+			if err != nil || limit <= 0 {
+				JSONError(c, http.StatusBadRequest, "Invalid limit")
+				return
+			}
+			window, err := time.ParseDuration(c.DefaultQuery("window", "1m"))
+			if err != nil || window <= 0 {
+				JSONError(c, http.StatusBadRequest, "Invalid window")
+				return
+			}
+			report, err := services.Migration.MigrateAll(c.Request.Context(), c.DefaultQuery("prefix", "rate_limit:ip:"), limit, window)
+			if err != nil {
+				JSONError(c, http.StatusInternalServerError, err.Error())
+				return
+			}
+			JSONResponse(c, http.StatusOK, report)
+		})
+
+		// Reset the usage of a key, e.g. ip:1.2.3.4
+		admin.DELETE("/limits/:key", func(c *gin.Context) {
+			resetter, ok := services.Limiter.(limitter.Resetter)
+			if !ok {
+				JSONError(c, http.StatusNotImplemented, "Limiter does not support reset")
+				return
+			}
+			limitKey := "rate_limit:" + c.Param("key")
+			if err := resetter.Reset(c.Request.Context(), limitKey); err != nil {
+				JSONError(c, http.StatusInternalServerError, err.Error())
+				return
+			}
+			services.Denials.Invalidate(limitKey)
+			JSONResponse(c, http.StatusOK, gin.H{
+				"message": "Rate limit reset",
+				"key":     c.Param("key"),
+			})
+		})
 	}
 }
 
 // setupRoutes sets up all HTTP routes
-func setupRoutes(router *gin.Engine, redisLimiter limitter.RateLimiter) {
+func setupRoutes(router *gin.Engine, services *Services) {
 	// Create adapter for the middleware
-	adapter := &RateLimiterAdapter{limiter: redisLimiter}
+	adapter := &RateLimiterAdapter{limiter: services.Limiter}
 	
 	// Health check endpoint (no rate limiting)
 	router.GET("/ping", func(c *gin.Context) {
@@ -444,7 +1258,10 @@ func setupRoutes(router *gin.Engine, redisLimiter limitter.RateLimiter) {
 
 	// API v1 routes with rate limiting
 	v1 := router.Group("/api/v1")
-	v1.Use(rateLimitMiddleware(adapter)) // Apply rate limiting to this group
+	v1.Use(rateLimitMiddleware(adapter, services)) // Apply rate limiting to this group
+	if services.Credits != nil && services.MeterCredits {
+		v1.Use(creditMiddleware(services.Credits))
+	}
 	{
 		// Status endpoint
 		v1.GET("/status", func(c *gin.Context) {
@@ -468,6 +1285,9 @@ func setupRoutes(router *gin.Engine, redisLimiter limitter.RateLimiter) {
 func main() {
 	// Load configuration
 	config := LoadConfig()
+	if err := validateLimiter(config); err != nil {
+		log.Fatalf("Invalid limiter configuration: %v", err)
+	}
 
 	// Set Gin mode based on environment
 	if config.Environment == "production" {
@@ -486,6 +1306,235 @@ func main() {
 
 	redisLimiter := limitter.NewRedisRateLimiter(redisClient, limiterConfig)
 
+	// Optionally decide with another algorithm, converting the sliding logs
+	// of the old one on first access
+	var apiLimiter limitter.RateLimiter = redisLimiter
+	var migration *limitter.MigratingRateLimiter
+	if config.Algorithm != string(limitter.AlgorithmSlidingLog) {
+		var target limitter.MigrationTarget
+		switch limitter.Algorithm(config.Algorithm) {
+		case limitter.AlgorithmGCRA:
+			target = limitter.NewGCRARateLimiter(redisClient)
+		case limitter.AlgorithmTokenBucket:
+			target = limitter.NewBucketRateLimiter(redisClient)
+		default:
+			log.Fatalf("Unknown RATE_LIMIT_ALGORITHM %q", config.Algorithm)
+		}
+		migration = limitter.NewMigratingRateLimiter(redisClient, target)
+		apiLimiter = target
+		if config.DualRead {
+			apiLimiter = migration
+		}
+	}
+
+	// Optionally coalesce concurrent checks into shared pipelines
+	if config.BatchDelay > 0 {
+		batcher := limitter.NewBatchingRateLimiter(redisLimiter, limitter.BatchConfig{
+			MaxDelay: config.BatchDelay,
+		})
+		defer batcher.Close()
+		apiLimiter = batcher
+	}
+
+	// Optionally count per region and replicate to the other regions' Redis
+	if config.Region != "" {
+		peers := make([]limitter.RedisClient, len(config.PeerRedisAddrs))
+		for i, addr := range config.PeerRedisAddrs {
+			// Peers may be down at startup, replication retries until they are back
+			peers[i] = &RedisClient{client: redis.NewClient(&redis.Options{
+				Addr:     addr,
+				Password: config.RedisPassword,
+			})}
+		}
+		regional := limitter.NewRegionalRateLimiter(redisClient, limitter.RegionalConfig{
+			Region:         config.Region,
+			Peers:          peers,
+			ReplicationLag: config.ReplicationLag,
+			OnReplicationError: func(counterKey string, err error) {
+				log.Printf("Replication error for %s: %v", counterKey, err)
+			},
+		})
+		// Push the last changes before exiting
+		replicationCtx, stopReplication := context.WithCancel(context.Background())
+		replicationDone := make(chan struct{})
+		go func() {
+			regional.Run(replicationCtx)
+			close(replicationDone)
+		}()
+		defer func() {
+			stopReplication()
+			<-replicationDone
+		}()
+		apiLimiter = regional
+	}
+
+	// Optionally serve decisions from quota leased into this instance
+	if config.HybridMaxError > 0 {
+		hybrid := limitter.NewHybridRateLimiter(redisClient, limitter.HybridConfig{
+			MaxError:  config.HybridMaxError,
+			Instances: config.Instances,
+		})
+		// Hand unused leases back so other instances can use them
+		defer func() {
+			releaseCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := hybrid.Release(releaseCtx); err != nil {
+				log.Printf("Failed to release leased quota: %v", err)
+			}
+		}()
+		apiLimiter = hybrid
+	}
+
+	// Optionally limit between peers without Redis
+	var peerLimiter *limitter.PeerRateLimiter
+	var memoryState *limitter.MemoryRateLimiter
+	// Connection checks run on the accept loop, so they fall back to local
+	// decisions instead of waiting on Redis
+	connLimiter := limitter.RateLimiter(limitter.NewFallbackRateLimiter(redisLimiter, limitter.FallbackConfig{
+		Instances: config.Instances,
+	}))
+	if config.LimiterBackend == "peer" {
+		if config.SelfURL == "" {
+			log.Fatalf("SELF_URL is required for the peer backend")
+		}
+		// The internal API charges and refunds any key, so it must not be open
+		if config.PeerToken == "" {
+			log.Fatalf("PEER_TOKEN is required for the peer backend")
+		}
+		peerLimiter = limitter.NewPeerRateLimiter(limitter.PeerConfig{
+			Self:  config.SelfURL,
+			Peers: config.Peers,
+			Token: config.PeerToken,
+			OnMembershipChange: func(alive []string) {
+				log.Printf("Rate limit peers changed, live peers: %v", alive)
+			},
+		})
+		peerCtx, stopPeers := context.WithCancel(context.Background())
+		defer stopPeers()
+		go peerLimiter.Run(peerCtx)
+		apiLimiter = peerLimiter
+		connLimiter = limitter.NewMemoryRateLimiter()
+		memoryState = peerLimiter.Local()
+	}
+	if config.LimiterBackend == "memory" {
+		memoryState = limitter.NewMemoryRateLimiter()
+		apiLimiter = memoryState
+		connLimiter = limitter.NewMemoryRateLimiter()
+	}
+
+	// Keep in-memory state across restarts
+	if memoryState != nil && config.SnapshotPath != "" {
+		restored, err := memoryState.LoadSnapshot(config.SnapshotPath)
+		if err != nil {
+			log.Printf("Failed to restore snapshot: %v", err)
+		} else {
+			log.Printf("Restored rate limit state of %d keys from %s", restored, config.SnapshotPath)
+		}
+
+		snapshotCtx, stopSnapshots := context.WithCancel(context.Background())
+		snapshotsDone := make(chan struct{})
+		go func() {
+			memoryState.RunSnapshots(snapshotCtx, config.SnapshotPath, config.SnapshotInterval, func(err error) {
+				log.Printf("Snapshot error: %v", err)
+			})
+			close(snapshotsDone)
+		}()
+		defer func() {
+			stopSnapshots()
+			<-snapshotsDone
+		}()
+	}
+
+	var budgetFallback middleware.BudgetFallback
+	switch config.BudgetFallback {
+	case "local":
+		budgetFallback = middleware.BudgetLocal
+	case "allow":
+		budgetFallback = middleware.BudgetAllow
+	case "deny":
+		budgetFallback = middleware.BudgetDeny
+	default:
+		log.Fatalf("Unknown RATE_LIMIT_BUDGET_FALLBACK %q", config.BudgetFallback)
+	}
+
+	// Decide locally with this instance's share of the limit while the backend
+	// is down, and past the latency budget unless another decision is configured
+	fallback := limitter.NewFallbackRateLimiter(apiLimiter, limitter.FallbackConfig{
+		Instances:    config.Instances,
+		PassOverruns: budgetFallback != middleware.BudgetLocal,
+		OnSwitch: func(event limitter.FallbackEvent) {
+			if event.Degraded {
+				log.Printf("Rate limiter degraded to local decisions: %v", event.Err)
+			} else {
+				log.Printf("Rate limiter recovered, using Redis again")
+			}
+		},
+	})
+	apiLimiter = fallback
+
+	// Optionally reject requests probabilistically as clients approach the limit
+	if config.EarlyThrottle > 0 {
+		apiLimiter = limitter.NewEarlyThrottler(apiLimiter, limitter.EarlyThrottleConfig{
+			Threshold: config.EarlyThrottle,
+		})
+	}
+
+	// Bans, heavy-hitter tracking, credits and overage need Redis, which the peer backend may run without
+	var bans *limitter.BanManager
+	var heavyHitters *limitter.HeavyHitterTracker
+	var credits *limitter.CreditStore
+	var overage *limitter.OverageRecorder
+	if redisClient.HealthCheck(context.Background()) == nil {
+		// Escalate repeated violations into temporary bans
+		bans = limitter.NewBanManager(redisClient, limitter.BanConfig{})
+
+		// Track the heaviest clients and warn when one dominates traffic
+		heavyHitters = limitter.NewHeavyHitterTracker(redisClient, limitter.HeavyHitterConfig{
+			AlertShare: 0.5,
+			OnAlert: func(alert limitter.HeavyHitterAlert) {
+				log.Printf("Heavy hitter: %s used %.0f%% of %s traffic for %d intervals",
+					alert.Key, alert.Share*100, alert.Policy, alert.Intervals)
+			},
+		})
+		watchCtx, stopWatch := context.WithCancel(context.Background())
+		defer stopWatch()
+		go heavyHitters.Watch(watchCtx, defaultPolicy)
+
+		// Prepaid credit balances for metered API keys
+		credits = limitter.NewCreditStore(redisClient, limitter.CreditConfig{
+			LowBalanceThreshold: int64(getIntEnv("CREDITS_LOW_BALANCE", 100)),
+			OnLowBalance: func(key string, balance int64) {
+				log.Printf("Low credit balance: %s has %d credits left", key, balance)
+			},
+		})
+
+		// Requests served above the soft limit, exported for billing
+		overage = limitter.NewOverageRecorder(redisClient, limitter.OverageConfig{})
+	}
+
+	services := &Services{
+		Limiter:        apiLimiter,
+		Bans:           bans,
+		HeavyHitters:   heavyHitters,
+		Credits:        credits,
+		MeterCredits:   config.CreditsMetered,
+		Overage:        overage,
+		Denials:        limitter.NewDenialCache(config.DenialTTL),
+		Fallback:       fallback,
+		Budget:         &middleware.BudgetStats{},
+		Memory:         memoryState,
+		SnapshotPath:   config.SnapshotPath,
+		State:          limitter.StateStore(limitter.NewRedisStateStore(redisClient)),
+		LatencyBudget:  config.LatencyBudget,
+		BudgetFallback: budgetFallback,
+		Migration:      migration,
+		OverageLimit:   config.OverageHardLimit,
+	}
+
+	if memoryState != nil {
+		services.State = memoryState
+	}
+
 	// Create Gin router
 	router := gin.Default()
 
@@ -508,7 +1557,11 @@ func main() {
 	})
 
 	// Setup routes
-	setupRoutes(router, redisLimiter)
+	setupRoutes(router, services)
+	setupAdminRoutes(router, config, services)
+	if peerLimiter != nil {
+		router.Any("/internal/ratelimit/*path", gin.WrapH(peerLimiter.Handler()))
+	}
 
 	// Create HTTP server
 	server := &http.Server{
@@ -519,10 +1572,23 @@ func main() {
 		MaxHeaderBytes: 1 << 20,
 	}
 
+	// Limit connection floods before HTTP parsing
+	listener, err := net.Listen("tcp", server.Addr)
+	if err != nil {
+		log.Fatalf("Failed to listen on port %s: %v", config.ServerPort, err)
+	}
+	if config.ConnRateLimit > 0 || config.MaxConnsPerIP > 0 {
+		listener = middleware.LimitListener(listener, &RateLimiterAdapter{limiter: connLimiter}, middleware.ListenerConfig{
+			WindowSize:     time.Minute,
+			MaxConnections: config.ConnRateLimit,
+			MaxConcurrent:  config.MaxConnsPerIP,
+		})
+	}
+
 	// Start server in a goroutine
 	go func() {
 		log.Printf("Starting server on port %s", config.ServerPort)
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
 			log.Fatalf("Server failed to start: %v", err)
 		}
 	}()
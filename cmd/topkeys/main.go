@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+// topResponse mirrors the JSON returned by GET /admin/top
+type topResponse struct {
+	Status int    `json:"status"`
+	Error  string `json:"error"`
+	Data   struct {
+		Policy    string `json:"policy"`
+		Intervals int    `json:"intervals"`
+		Keys      []struct {
+			Key   string  `json:"key"`
+			Count int64   `json:"count"`
+			Share float64 `json:"share"`
+		} `json:"keys"`
+	} `json:"data"`
+}
+
+func main() {
+	addr := flag.String("addr", "http://localhost:8081", "rate limiter server address")
+	token := flag.String("token", os.Getenv("ADMIN_TOKEN"), "admin token (defaults to $ADMIN_TOKEN)")
+	policy := flag.String("policy", "api_v1", "policy to report on")
+	n := flag.Int("n", 10, "number of keys to list")
+	intervals := flag.Int("intervals", 5, "number of recent intervals to sum")
+	flag.Parse()
+
+	query := url.Values{}
+	query.Set("policy", *policy)
+	query.Set("n", fmt.Sprintf("%d", *n))
+	query.Set("intervals", fmt.Sprintf("%d", *intervals))
+
+	req, err := http.NewRequest(http.MethodGet, *addr+"/admin/top?"+query.Encode(), nil)
+	if err != nil {
+		log.Fatalf("Failed to build request: %v", err)
+	}
+	req.Header.Set("X-Admin-Token", *token)
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		log.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var top topResponse
+	if err := json.NewDecoder(resp.Body).Decode(&top); err != nil {
+		log.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		log.Fatalf("Server returned %d: %s", resp.StatusCode, top.Error)
+	}
+
+	fmt.Printf("Top %d keys for policy %q over the last %d intervals\n\n", *n, top.Data.Policy, top.Data.Intervals)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "RANK\tKEY\tCOUNT\tSHARE")
+	for i, k := range top.Data.Keys {
+		fmt.Fprintf(w, "%d\t%s\t%d\t%.1f%%\n", i+1, k.Key, k.Count, k.Share*100)
+	}
+	w.Flush()
+}
@@ -0,0 +1,181 @@
+// internal/limitter/ban.go
+package limitter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// BanConfig holds configuration for automatic temporary bans
+type BanConfig struct {
+	// Number of limit violations within ViolationWindow that triggers a ban
+	Threshold int
+	// Window in which violations are counted
+	ViolationWindow time.Duration
+	// Duration of the first ban
+	BaseDuration time.Duration
+	// Upper bound for escalated bans
+	MaxDuration time.Duration
+	// How long past offenses are remembered for escalation
+	OffenseTTL time.Duration
+	// Key prefix for ban related Redis keys
+	KeyPrefix string
+}
+
+// BanRecord describes an active ban
+type BanRecord struct {
+	Key       string
+	Offense   int
+	ExpiresAt time.Time
+}
+
+// BanManager escalates repeated rate limit violations into temporary bans
+type BanManager struct {
+	client RedisClient
+	config BanConfig
+}
+
+// NewBanManager creates a new Redis-based ban manager
+func NewBanManager(client RedisClient, config BanConfig) *BanManager {
+	if config.Threshold <= 0 {
+		config.Threshold = 5
+	}
+	if config.ViolationWindow <= 0 {
+		config.ViolationWindow = 10 * time.Minute
+	}
+	if config.BaseDuration <= 0 {
+		config.BaseDuration = time.Minute
+	}
+	if config.MaxDuration <= 0 {
+		config.MaxDuration = 24 * time.Hour
+	}
+	if config.OffenseTTL <= 0 {
+		config.OffenseTTL = 24 * time.Hour
+	}
+	if config.KeyPrefix == "" {
+		config.KeyPrefix = "rate_limit:"
+	}
+
+	return &BanManager{
+		client: client,
+		config: config,
+	}
+}
+
+// CheckBan returns the active ban for key, or nil if the key is not banned
+func (b *BanManager) CheckBan(ctx context.Context, key string) (*BanRecord, error) {
+	val, err := b.client.Get(ctx, b.banKey(key)).Result()
+	if err != nil {
+		if errors.Is(err, Nil) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get ban: %w", err)
+	}
+
+	return parseBanRecord(key, val)
+}
+
+// RecordViolation counts a limit violation for key and bans the key once the
+// threshold is reached. Each ban doubles the previous duration.
+func (b *BanManager) RecordViolation(ctx context.Context, key string) (*BanRecord, error) {
+	violations, err := b.client.Incr(ctx, b.violationKey(key)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to record violation: %w", err)
+	}
+
+	// Start the violation window on the first offense
+	if violations == 1 {
+		if err := b.client.Expire(ctx, b.violationKey(key), b.config.ViolationWindow).Err(); err != nil {
+			return nil, fmt.Errorf("failed to set violation expiry: %w", err)
+		}
+	}
+
+	if violations < int64(b.config.Threshold) {
+		return nil, nil
+	}
+
+	offense, err := b.client.Incr(ctx, b.offenseKey(key)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to record offense: %w", err)
+	}
+	if err := b.client.Expire(ctx, b.offenseKey(key), b.config.OffenseTTL).Err(); err != nil {
+		return nil, fmt.Errorf("failed to set offense expiry: %w", err)
+	}
+
+	duration := b.banDuration(int(offense))
+	record := &BanRecord{
+		Key:       key,
+		Offense:   int(offense),
+		ExpiresAt: time.Now().Add(duration),
+	}
+
+	value := fmt.Sprintf("%d:%d", record.Offense, record.ExpiresAt.Unix())
+	if err := b.client.Set(ctx, b.banKey(key), value, duration).Err(); err != nil {
+		return nil, fmt.Errorf("failed to store ban: %w", err)
+	}
+
+	// Violations that led to this ban should not count towards the next one
+	if err := b.client.Del(ctx, b.violationKey(key)).Err(); err != nil {
+		return nil, fmt.Errorf("failed to reset violations: %w", err)
+	}
+
+	return record, nil
+}
+
+// Unban lifts an active ban and clears the violation history for key
+func (b *BanManager) Unban(ctx context.Context, key string) error {
+	if err := b.client.Del(ctx, b.banKey(key), b.violationKey(key), b.offenseKey(key)).Err(); err != nil {
+		return fmt.Errorf("failed to lift ban: %w", err)
+	}
+	return nil
+}
+
+// ResetViolations clears the violation and offense history for key
+// without lifting an active ban
+func (b *BanManager) ResetViolations(ctx context.Context, key string) error {
+	if err := b.client.Del(ctx, b.violationKey(key), b.offenseKey(key)).Err(); err != nil {
+		return fmt.Errorf("failed to reset violations: %w", err)
+	}
+	return nil
+}
+
+// banDuration calculates the ban duration for the given offense number
+func (b *BanManager) banDuration(offense int) time.Duration {
+	duration := b.config.BaseDuration
+	for i := 1; i < offense; i++ {
+		duration *= 2
+		if duration >= b.config.MaxDuration {
+			return b.config.MaxDuration
+		}
+	}
+	return duration
+}
+
+func (b *BanManager) banKey(key string) string {
+	return b.config.KeyPrefix + "ban:" + key
+}
+
+func (b *BanManager) violationKey(key string) string {
+	return b.config.KeyPrefix + "violations:" + key
+}
+
+func (b *BanManager) offenseKey(key string) string {
+	return b.config.KeyPrefix + "offenses:" + key
+}
+
+// parseBanRecord parses a stored "offense:expiry" ban value
+func parseBanRecord(key, val string) (*BanRecord, error) {
+	var offense int
+	var expiry int64
+	if _, err := fmt.Sscanf(val, "%d:%d", &offense, &expiry); err != nil {
+		return nil, fmt.Errorf("invalid ban record %q: %w", val, err)
+	}
+
+	return &BanRecord{
+		Key:       key,
+		Offense:   offense,
+		ExpiresAt: time.Unix(expiry, 0),
+	}, nil
+}
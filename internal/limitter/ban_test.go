@@ -0,0 +1,156 @@
+// internal/limitter/ban_test.go
+package limitter
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestBanEscalation(t *testing.T) {
+	tests := []struct {
+		name       string
+		violations int
+		offenses   []int
+		durations  []time.Duration
+	}{
+		{
+			name:       "below threshold",
+			violations: 2,
+		},
+		{
+			name:       "first ban",
+			violations: 3,
+			offenses:   []int{1},
+			durations:  []time.Duration{time.Minute},
+		},
+		{
+			name:       "bans double",
+			violations: 9,
+			offenses:   []int{1, 2, 3},
+			durations:  []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute},
+		},
+		{
+			name:       "bans are capped",
+			violations: 15,
+			offenses:   []int{1, 2, 3, 4, 5},
+			durations:  []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 5 * time.Minute, 5 * time.Minute},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, client := newTestRedis(t)
+			bans := NewBanManager(client, BanConfig{Threshold: 3, MaxDuration: 5 * time.Minute})
+			ctx := context.Background()
+
+			var records []*BanRecord
+			for i := 0; i < tt.violations; i++ {
+				start := time.Now()
+				record, err := bans.RecordViolation(ctx, "client")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if record == nil {
+					continue
+				}
+				records = append(records, record)
+
+				want := tt.durations[len(records)-1]
+				if got := record.ExpiresAt.Sub(start); got < want || got > want+time.Second {
+					t.Errorf("ban %d lasts %v, want %v", len(records), got, want)
+				}
+			}
+
+			if len(records) != len(tt.offenses) {
+				t.Fatalf("got %d bans, want %d", len(records), len(tt.offenses))
+			}
+			for i, record := range records {
+				if record.Offense != tt.offenses[i] {
+					t.Errorf("ban %d is offense %d, want %d", i+1, record.Offense, tt.offenses[i])
+				}
+			}
+		})
+	}
+}
+
+func TestCheckBan(t *testing.T) {
+	tests := []struct {
+		name   string
+		setup  func(context.Context, *BanManager) error
+		banned bool
+	}{
+		{
+			name:  "never violated",
+			setup: func(ctx context.Context, b *BanManager) error { return nil },
+		},
+		{
+			name: "banned",
+			setup: func(ctx context.Context, b *BanManager) error {
+				_, err := b.RecordViolation(ctx, "client")
+				return err
+			},
+			banned: true,
+		},
+		{
+			name: "unbanned",
+			setup: func(ctx context.Context, b *BanManager) error {
+				if _, err := b.RecordViolation(ctx, "client"); err != nil {
+					return err
+				}
+				return b.Unban(ctx, "client")
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, client := newTestRedis(t)
+			bans := NewBanManager(client, BanConfig{Threshold: 1})
+			ctx := context.Background()
+
+			if err := tt.setup(ctx, bans); err != nil {
+				t.Fatal(err)
+			}
+
+			record, err := bans.CheckBan(ctx, "client")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := record != nil; got != tt.banned {
+				t.Fatalf("got banned %v, want %v", got, tt.banned)
+			}
+			if record != nil && (record.Key != "client" || record.Offense != 1) {
+				t.Errorf("got ban %+v, want offense 1 of client", record)
+			}
+		})
+	}
+}
+
+func TestBanExpiry(t *testing.T) {
+	server, client := newTestRedis(t)
+	bans := NewBanManager(client, BanConfig{Threshold: 2, ViolationWindow: time.Minute})
+	ctx := context.Background()
+
+	// Violations outside the window don't add up
+	if _, err := bans.RecordViolation(ctx, "client"); err != nil {
+		t.Fatal(err)
+	}
+	server.FastForward(2 * time.Minute)
+	record, err := bans.RecordViolation(ctx, "client")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record != nil {
+		t.Fatalf("got ban %+v for violations in different windows", record)
+	}
+
+	// The ban is lifted once its duration passes
+	if record, err = bans.RecordViolation(ctx, "client"); err != nil || record == nil {
+		t.Fatalf("got ban %+v, %v, want a ban", record, err)
+	}
+	server.FastForward(time.Minute + time.Second)
+	if record, err = bans.CheckBan(ctx, "client"); err != nil || record != nil {
+		t.Errorf("got ban %+v, %v after it expired, want none", record, err)
+	}
+}
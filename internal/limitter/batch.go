@@ -0,0 +1,193 @@
+// internal/limitter/batch.go
+package limitter
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// BatchConfig holds configuration for write batching
+type BatchConfig struct {
+	// How long to gather concurrent checks before sending them (default 200µs)
+	MaxDelay time.Duration
+	// Send the batch early once it holds this many checks (default 128)
+	MaxBatch int
+	// Timeout for executing one batch (default 5s)
+	Timeout time.Duration
+}
+
+// BatchingRateLimiter gathers concurrent checks from many goroutines, across
+// keys, and sends them to Redis as a single pipeline. Each caller receives
+// the result of its own check.
+type BatchingRateLimiter struct {
+	limiter *RedisRateLimiter
+	config  BatchConfig
+
+	mu      sync.Mutex
+	pending []*batchCall
+	timer   *time.Timer
+	closed  bool
+	flushes sync.WaitGroup
+}
+
+// batchCall is one check waiting in a batch
+type batchCall struct {
+	key    string
+	limit  int
+	window time.Duration
+	n      int
+	now    time.Time
+	done   chan struct{}
+	result *RateLimitResult
+	err    error
+}
+
+// NewBatchingRateLimiter wraps limiter with write batching
+func NewBatchingRateLimiter(limiter *RedisRateLimiter, config BatchConfig) *BatchingRateLimiter {
+	if config.MaxDelay <= 0 {
+		config.MaxDelay = 200 * time.Microsecond
+	}
+	if config.MaxBatch <= 0 {
+		config.MaxBatch = 128
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 5 * time.Second
+	}
+
+	return &BatchingRateLimiter{
+		limiter: limiter,
+		config:  config,
+	}
+}
+
+// IsAllowed checks if a request is allowed as part of the next batch
+func (b *BatchingRateLimiter) IsAllowed(ctx context.Context, key string, limit int, window time.Duration) (*RateLimitResult, error) {
+	return b.IsAllowedN(ctx, key, limit, window, 1)
+}
+
+// IsAllowedN checks if a request costing n units is allowed as part of the
+// next batch. Like the unbatched limiter, a request costing more than limit
+// is rejected without being logged.
+func (b *BatchingRateLimiter) IsAllowedN(ctx context.Context, key string, limit int, window time.Duration, n int) (*RateLimitResult, error) {
+	if n < 0 {
+		return nil, ErrInvalidCost
+	}
+	if n > limit {
+		return rejectOversized(b.limiter.Peek(ctx, key, limit, window))
+	}
+
+	call := &batchCall{
+		key:    key,
+		limit:  limit,
+		window: window,
+		n:      n,
+		now:    time.Now(),
+		done:   make(chan struct{}),
+	}
+
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return b.limiter.IsAllowedN(ctx, key, limit, window, n)
+	}
+
+	b.pending = append(b.pending, call)
+	if len(b.pending) >= b.config.MaxBatch {
+		b.flushLocked()
+	} else if len(b.pending) == 1 {
+		b.timer = time.AfterFunc(b.config.MaxDelay, b.flush)
+	}
+	b.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.result, call.err
+	case <-ctx.Done():
+		// The check is still sent, so give back whatever it charged
+		go func() {
+			<-call.done
+			if call.result != nil && call.result.Reservation != nil {
+				refundCtx, cancel := context.WithTimeout(context.Background(), b.config.Timeout)
+				defer cancel()
+				call.result.Reservation.Refund(refundCtx)
+			}
+		}()
+		return nil, ctx.Err()
+	}
+}
+
+// Adjust implements CostLimiter without batching
+func (b *BatchingRateLimiter) Adjust(ctx context.Context, key string, window time.Duration, delta int) error {
+	return b.limiter.Adjust(ctx, key, window, delta)
+}
+
+// Peek implements Peeker without batching
+func (b *BatchingRateLimiter) Peek(ctx context.Context, key string, limit int, window time.Duration) (*RateLimitResult, error) {
+	return b.limiter.Peek(ctx, key, limit, window)
+}
+
+// Reset implements Resetter without batching
+func (b *BatchingRateLimiter) Reset(ctx context.Context, key string) error {
+	return b.limiter.Reset(ctx, key)
+}
+
+// Close sends any pending checks and waits for in-flight batches. Checks made
+// after Close are sent individually.
+func (b *BatchingRateLimiter) Close() {
+	b.mu.Lock()
+	b.closed = true
+	b.flushLocked()
+	b.mu.Unlock()
+
+	b.flushes.Wait()
+}
+
+// flush sends the pending batch when the delay timer fires
+func (b *BatchingRateLimiter) flush() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.flushLocked()
+}
+
+// flushLocked hands the pending batch to a goroutine. b.mu must be held.
+func (b *BatchingRateLimiter) flushLocked() {
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	if len(b.pending) == 0 {
+		return
+	}
+
+	batch := b.pending
+	b.pending = nil
+
+	b.flushes.Add(1)
+	go func() {
+		defer b.flushes.Done()
+		b.exec(batch)
+	}()
+}
+
+// exec sends batch as one pipeline and fans the results back to the callers
+func (b *BatchingRateLimiter) exec(batch []*batchCall) {
+	ctx, cancel := context.WithTimeout(context.Background(), b.config.Timeout)
+	defer cancel()
+
+	pipe := b.limiter.client.Pipeline()
+	entries := make([]*logEntry, len(batch))
+	for i, call := range batch {
+		entries[i] = b.limiter.queueLog(ctx, pipe, call.key, call.window, call.n, call.now)
+		// Expire rides along so the batch stays one round trip
+		pipe.Expire(ctx, call.key, call.window+time.Minute)
+	}
+
+	// Failures are reported per check through each script reply
+	pipe.Exec(ctx)
+
+	for i, call := range batch {
+		call.result, call.err = b.limiter.logResult(entries[i], call.limit)
+		close(call.done)
+	}
+}
@@ -0,0 +1,186 @@
+// internal/limitter/bruteforce.go
+package limitter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// BruteForceConfig holds configuration for credential endpoint protection
+type BruteForceConfig struct {
+	// Failed attempts allowed for one username from one IP before lockout
+	MaxFailuresPerUserIP int
+	// Failed attempts for one username across all IPs after which attempts
+	// need a challenge, such as a CAPTCHA
+	MaxFailuresPerUser int
+	// Lock the username across all IPs after MaxFailuresPerUser failures
+	// instead of asking for a challenge. Anyone can then lock any account by
+	// failing from enough IPs, so this is opt-in.
+	LockUsername bool
+	// Window in which failed attempts are counted
+	FailureWindow time.Duration
+	// Duration of the first lockout, doubled for every further lockout
+	LockoutDuration time.Duration
+	// Upper bound for escalated lockouts
+	MaxLockout time.Duration
+	// Key prefix for Redis keys
+	KeyPrefix string
+}
+
+// LoginGuard tracks failed credential attempts per username and IP
+type LoginGuard interface {
+	Check(ctx context.Context, username, ip string) (*BanRecord, error)
+	Challenge(ctx context.Context, username string) (bool, error)
+	RecordFailure(ctx context.Context, username, ip string) (*BanRecord, error)
+	RecordSuccess(ctx context.Context, username, ip string) error
+}
+
+// BruteForceGuard counts failed login or OTP attempts and applies
+// progressive lockouts per username+IP. Failures for a username across IPs
+// ask for a challenge, or lock the username with LockUsername.
+type BruteForceGuard struct {
+	client RedisClient
+	config BruteForceConfig
+	userIP *BanManager
+	// user locks usernames across IPs, nil unless LockUsername is set
+	user *BanManager
+}
+
+// NewBruteForceGuard creates a new Redis-based brute-force guard
+func NewBruteForceGuard(client RedisClient, config BruteForceConfig) *BruteForceGuard {
+	if config.MaxFailuresPerUserIP <= 0 {
+		config.MaxFailuresPerUserIP = 5
+	}
+	if config.MaxFailuresPerUser <= 0 {
+		config.MaxFailuresPerUser = 20
+	}
+	if config.FailureWindow <= 0 {
+		config.FailureWindow = 15 * time.Minute
+	}
+	if config.LockoutDuration <= 0 {
+		config.LockoutDuration = time.Minute
+	}
+	if config.MaxLockout <= 0 {
+		config.MaxLockout = time.Hour
+	}
+	if config.KeyPrefix == "" {
+		config.KeyPrefix = "rate_limit:bruteforce:"
+	}
+
+	newManager := func(threshold int) *BanManager {
+		return NewBanManager(client, BanConfig{
+			Threshold:       threshold,
+			ViolationWindow: config.FailureWindow,
+			BaseDuration:    config.LockoutDuration,
+			MaxDuration:     config.MaxLockout,
+			OffenseTTL:      24 * time.Hour,
+			KeyPrefix:       config.KeyPrefix,
+		})
+	}
+
+	guard := &BruteForceGuard{
+		client: client,
+		config: config,
+		userIP: newManager(config.MaxFailuresPerUserIP),
+	}
+	if config.LockUsername {
+		guard.user = newManager(config.MaxFailuresPerUser)
+	}
+	return guard
+}
+
+// Check returns the active lockout for the username, or nil if attempts are
+// allowed. A lockout of the username+IP takes precedence.
+func (g *BruteForceGuard) Check(ctx context.Context, username, ip string) (*BanRecord, error) {
+	lockout, err := g.userIP.CheckBan(ctx, userIPKey(username, ip))
+	if err != nil || lockout != nil || g.user == nil {
+		return lockout, err
+	}
+	return g.user.CheckBan(ctx, userKey(username))
+}
+
+// Challenge reports whether attempts for the username need a challenge
+// because it failed MaxFailuresPerUser times across all IPs
+func (g *BruteForceGuard) Challenge(ctx context.Context, username string) (bool, error) {
+	val, err := g.client.Get(ctx, g.failureKey(username)).Result()
+	if err != nil {
+		if errors.Is(err, Nil) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to get failures: %w", err)
+	}
+	failures, err := strconv.Atoi(val)
+	if err != nil {
+		return false, fmt.Errorf("invalid failure count: %w", err)
+	}
+	return failures >= g.config.MaxFailuresPerUser, nil
+}
+
+// RecordFailure counts a failed attempt and returns the lockout it triggered, if any
+func (g *BruteForceGuard) RecordFailure(ctx context.Context, username, ip string) (*BanRecord, error) {
+	lockout, err := g.userIP.RecordViolation(ctx, userIPKey(username, ip))
+	if err != nil {
+		return nil, err
+	}
+
+	failures, err := g.client.Incr(ctx, g.failureKey(username)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to record failure: %w", err)
+	}
+	if failures == 1 {
+		if err := g.client.Expire(ctx, g.failureKey(username), g.config.FailureWindow).Err(); err != nil {
+			return nil, fmt.Errorf("failed to set failure expiry: %w", err)
+		}
+	}
+
+	if lockout != nil || g.user == nil {
+		return lockout, nil
+	}
+	return g.user.RecordViolation(ctx, userKey(username))
+}
+
+// RecordSuccess resets the failure counters after a successful attempt
+func (g *BruteForceGuard) RecordSuccess(ctx context.Context, username, ip string) error {
+	if err := g.userIP.ResetViolations(ctx, userIPKey(username, ip)); err != nil {
+		return err
+	}
+	if err := g.client.Del(ctx, g.failureKey(username)).Err(); err != nil {
+		return fmt.Errorf("failed to reset failures: %w", err)
+	}
+	if g.user == nil {
+		return nil
+	}
+	return g.user.ResetViolations(ctx, userKey(username))
+}
+
+// Unlock lifts all lockouts and challenges for the username
+func (g *BruteForceGuard) Unlock(ctx context.Context, username, ip string) error {
+	if ip != "" {
+		if err := g.userIP.Unban(ctx, userIPKey(username, ip)); err != nil {
+			return err
+		}
+	}
+	if err := g.client.Del(ctx, g.failureKey(username)).Err(); err != nil {
+		return fmt.Errorf("failed to reset failures: %w", err)
+	}
+	if g.user == nil {
+		return nil
+	}
+	return g.user.Unban(ctx, userKey(username))
+}
+
+// failureKey counts the failures of a username across all IPs
+func (g *BruteForceGuard) failureKey(username string) string {
+	return g.config.KeyPrefix + "failures:" + userKey(username)
+}
+
+func userIPKey(username, ip string) string {
+	return "user_ip:" + username + ":" + ip
+}
+
+func userKey(username string) string {
+	return "user:" + username
+}
@@ -0,0 +1,119 @@
+// internal/limitter/bruteforce_test.go
+package limitter
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestBruteForceLockout(t *testing.T) {
+	_, client := newTestRedis(t)
+	guard := NewBruteForceGuard(client, BruteForceConfig{MaxFailuresPerUserIP: 3})
+	ctx := context.Background()
+
+	for i := 1; i <= 3; i++ {
+		lockout, err := guard.RecordFailure(ctx, "alice", "10.0.0.1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (lockout != nil) != (i == 3) {
+			t.Fatalf("failure %d: got lockout %v", i, lockout)
+		}
+	}
+
+	if lockout, err := guard.Check(ctx, "alice", "10.0.0.1"); err != nil || lockout == nil {
+		t.Errorf("got lockout %v err %v after the third failure, want a lockout", lockout, err)
+	}
+	// Other IPs and usernames are not locked
+	if lockout, err := guard.Check(ctx, "alice", "10.0.0.2"); err != nil || lockout != nil {
+		t.Errorf("got lockout %v err %v for another IP", lockout, err)
+	}
+	if lockout, err := guard.Check(ctx, "bob", "10.0.0.1"); err != nil || lockout != nil {
+		t.Errorf("got lockout %v err %v for another username", lockout, err)
+	}
+}
+
+func TestBruteForceAcrossIPs(t *testing.T) {
+	tests := []struct {
+		name         string
+		lockUsername bool
+		challenge    bool
+		locked       bool
+	}{
+		{name: "challenge", challenge: true},
+		{name: "lock username", lockUsername: true, challenge: true, locked: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, client := newTestRedis(t)
+			guard := NewBruteForceGuard(client, BruteForceConfig{
+				MaxFailuresPerUserIP: 5,
+				MaxFailuresPerUser:   4,
+				LockUsername:         tt.lockUsername,
+			})
+			ctx := context.Background()
+
+			// One failure per IP never locks the username+IP
+			for i := 0; i < 4; i++ {
+				if _, err := guard.RecordFailure(ctx, "alice", fmt.Sprintf("10.0.0.%d", i)); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			challenge, err := guard.Challenge(ctx, "alice")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if challenge != tt.challenge {
+				t.Errorf("got challenge %v, want %v", challenge, tt.challenge)
+			}
+			lockout, err := guard.Check(ctx, "alice", "10.0.0.9")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (lockout != nil) != tt.locked {
+				t.Errorf("got lockout %v from a new IP, want locked=%v", lockout, tt.locked)
+			}
+		})
+	}
+}
+
+func TestBruteForceSuccessResets(t *testing.T) {
+	_, client := newTestRedis(t)
+	guard := NewBruteForceGuard(client, BruteForceConfig{MaxFailuresPerUserIP: 3, MaxFailuresPerUser: 2})
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if _, err := guard.RecordFailure(ctx, "alice", "10.0.0.1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := guard.RecordSuccess(ctx, "alice", "10.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if challenge, err := guard.Challenge(ctx, "alice"); err != nil || challenge {
+		t.Errorf("got challenge %v err %v after a success", challenge, err)
+	}
+	// The failures before the success don't count towards a lockout
+	if lockout, err := guard.RecordFailure(ctx, "alice", "10.0.0.1"); err != nil || lockout != nil {
+		t.Errorf("got lockout %v err %v after a success", lockout, err)
+	}
+}
+
+func TestBruteForceLockoutExpires(t *testing.T) {
+	server, client := newTestRedis(t)
+	guard := NewBruteForceGuard(client, BruteForceConfig{MaxFailuresPerUserIP: 1, LockoutDuration: time.Minute})
+	ctx := context.Background()
+
+	if lockout, err := guard.RecordFailure(ctx, "alice", "10.0.0.1"); err != nil || lockout == nil {
+		t.Fatalf("got lockout %v err %v, want a lockout", lockout, err)
+	}
+	server.FastForward(2 * time.Minute)
+	if lockout, err := guard.Check(ctx, "alice", "10.0.0.1"); err != nil || lockout != nil {
+		t.Errorf("got lockout %v err %v after it expired", lockout, err)
+	}
+}
@@ -0,0 +1,170 @@
+// internal/limitter/bucket.go
+package limitter
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// bucketScript implements a token bucket holding limit tokens and refilled
+// at limit per window. The hash at KEYS[1] stores the tokens left and the
+// time of the last refill in microseconds. When the key is missing and the
+// legacy sliding log KEYS[2] is given, the bucket starts with the tokens the
+// cost of the log's requests in the window leaves. Returns {allowed,
+// remaining, reset_us, retry_us, migrated}, where a denied request resets
+// once it would be allowed.
+const bucketScript = `
+local now = tonumber(ARGV[1])
+local capacity = tonumber(ARGV[2])
+local window = tonumber(ARGV[3])
+local n = tonumber(ARGV[4])
+local rate = capacity / window
+local migrated = 0
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1])
+local ts = tonumber(state[2])
+if not tokens or not ts then
+	tokens = capacity
+	ts = now
+	if #KEYS > 1 and redis.call('TYPE', KEYS[2]).ok == 'zset' then
+		local used = 0
+		for _, member in ipairs(redis.call('ZRANGEBYSCORE', KEYS[2], '(' .. ARGV[5], '+inf')) do
+			used = used + tonumber(string.match(member, ':(%d+)$') or '1')
+		end
+		tokens = math.max(capacity - used, 0)
+		migrated = 1
+	end
+end
+tokens = math.min(capacity, tokens + math.max(now - ts, 0) * rate)
+
+local allowed = 0
+if tokens >= n then
+	tokens = tokens - n
+	allowed = 1
+end
+
+local reset = now + math.ceil((capacity - tokens) / rate)
+if allowed == 1 or migrated == 1 then
+	redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', string.format('%d', now))
+	redis.call('PEXPIRE', KEYS[1], math.ceil(window / 1000) + 60000)
+end
+if allowed == 0 then
+	local retry = math.ceil((n - tokens) / rate)
+	return {0, math.floor(tokens), now + retry, retry, migrated}
+end
+return {1, math.floor(tokens), reset, 0, migrated}
+`
+
+// bucketAdjustScript adds ARGV[1] tokens to the bucket at KEYS[1], taking
+// them for a negative value. Refills cap the tokens at the capacity again.
+const bucketAdjustScript = `
+if redis.call('EXISTS', KEYS[1]) == 1 then
+	redis.call('HINCRBYFLOAT', KEYS[1], 'tokens', ARGV[1])
+end
+return 1
+`
+
+// BucketRateLimiter implements the token bucket algorithm in Redis. A key
+// holds up to limit tokens, refilled continuously at limit per window.
+type BucketRateLimiter struct {
+	client RedisClient
+}
+
+// NewBucketRateLimiter creates a new Redis-based token bucket rate limiter
+func NewBucketRateLimiter(client RedisClient) *BucketRateLimiter {
+	return &BucketRateLimiter{client: client}
+}
+
+// IsAllowed checks if a request is allowed based on rate limits
+func (b *BucketRateLimiter) IsAllowed(ctx context.Context, key string, limit int, window time.Duration) (*RateLimitResult, error) {
+	return b.IsAllowedN(ctx, key, limit, window, 1)
+}
+
+// IsAllowedN checks if a request costing n tokens is allowed. A request
+// costing more than limit never fits and is rejected without being charged.
+func (b *BucketRateLimiter) IsAllowedN(ctx context.Context, key string, limit int, window time.Duration, n int) (*RateLimitResult, error) {
+	result, _, err := b.decide(ctx, key, "", limit, window, n)
+	return result, err
+}
+
+// Adjust implements CostLimiter, taking delta more tokens from the bucket
+// of key or returning them when delta is negative
+func (b *BucketRateLimiter) Adjust(ctx context.Context, key string, window time.Duration, delta int) error {
+	if delta == 0 {
+		return nil
+	}
+	if err := b.client.Eval(ctx, bucketAdjustScript, []string{b.versionedKey(key)}, -delta).Err(); err != nil {
+		return fmt.Errorf("failed to adjust cost: %w", err)
+	}
+	return nil
+}
+
+// Reset refills the bucket of key
+func (b *BucketRateLimiter) Reset(ctx context.Context, key string) error {
+	if err := b.client.Del(ctx, b.versionedKey(key)).Err(); err != nil {
+		return fmt.Errorf("failed to reset key: %w", err)
+	}
+	return nil
+}
+
+// Algorithm implements MigrationTarget
+func (b *BucketRateLimiter) Algorithm() Algorithm {
+	return AlgorithmTokenBucket
+}
+
+// versionedKey implements MigrationTarget
+func (b *BucketRateLimiter) versionedKey(key string) string {
+	return VersionedKey(key, AlgorithmTokenBucket)
+}
+
+// decide implements MigrationTarget
+func (b *BucketRateLimiter) decide(ctx context.Context, key, legacyKey string, limit int, window time.Duration, n int) (*RateLimitResult, bool, error) {
+	if limit <= 0 {
+		return nil, false, fmt.Errorf("limit must be greater than 0")
+	}
+	if n < 0 {
+		return nil, false, ErrInvalidCost
+	}
+
+	now := time.Now()
+	bucketKey := b.versionedKey(key)
+
+	keys := []string{bucketKey}
+	if legacyKey != "" {
+		keys = append(keys, legacyKey)
+	}
+	windowStart := fmt.Sprintf("%.0f", float64(now.Add(-window).UnixNano()))
+
+	val, err := b.client.Eval(ctx, bucketScript, keys, now.UnixMicro(), limit, window.Microseconds(), n, windowStart).Result()
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to run token bucket: %w", err)
+	}
+
+	reply, ok := val.([]interface{})
+	if !ok || len(reply) != 5 {
+		return nil, false, fmt.Errorf("unexpected token bucket reply: %v", val)
+	}
+	allowed, _ := reply[0].(int64)
+	remaining, _ := reply[1].(int64)
+	reset, _ := reply[2].(int64)
+	retry, _ := reply[3].(int64)
+	migrated, _ := reply[4].(int64)
+
+	result := &RateLimitResult{
+		Allowed:    allowed == 1,
+		Remaining:  int(remaining),
+		ResetTime:  time.UnixMicro(reset),
+		RetryAfter: time.Duration(retry) * time.Microsecond,
+	}
+	if result.Allowed && n > 0 {
+		result.Reservation = newReservation(func(ctx context.Context) error {
+			if err := b.client.Eval(ctx, bucketAdjustScript, []string{bucketKey}, n).Err(); err != nil {
+				return fmt.Errorf("failed to refund reservation: %w", err)
+			}
+			return nil
+		})
+	}
+	return result, migrated == 1, nil
+}
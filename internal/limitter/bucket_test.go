@@ -0,0 +1,100 @@
+// internal/limitter/bucket_test.go
+package limitter
+
+import (
+	"context"
+	"testing"
+)
+
+func TestBucketScript(t *testing.T) {
+	// 10 tokens refilled over a minute, one every 6s
+	const window, refill = int64(60_000_000), int64(6_000_000)
+
+	tests := []struct {
+		name  string
+		steps []scriptStep
+	}{
+		{
+			name: "burst up to the capacity",
+			steps: []scriptStep{
+				{at: 0, n: 10, allowed: true, remaining: 0},
+				{at: 0, n: 1, allowed: false, remaining: 0, retryUs: refill},
+			},
+		},
+		{
+			name: "cost that doesn't fit is denied without taking tokens",
+			steps: []scriptStep{
+				{at: 0, n: 4, allowed: true, remaining: 6},
+				{at: 0, n: 7, allowed: false, remaining: 6, retryUs: refill},
+				{at: 0, n: 6, allowed: true, remaining: 0},
+			},
+		},
+		{
+			name: "cost above the limit is denied and doesn't block smaller ones",
+			steps: []scriptStep{
+				{at: 0, n: 11, allowed: false, remaining: 10, retryUs: refill},
+				{at: 0, n: 10, allowed: true, remaining: 0},
+			},
+		},
+		{
+			name: "tokens refill over time",
+			steps: []scriptStep{
+				{at: 0, n: 4, allowed: true, remaining: 6},
+				{at: refill, n: 7, allowed: true, remaining: 0},
+				{at: refill, n: 1, allowed: false, remaining: 0, retryUs: refill},
+			},
+		},
+		{
+			name: "refills stop at the capacity",
+			steps: []scriptStep{
+				{at: 0, n: 10, allowed: true, remaining: 0},
+				{at: 3 * window, n: 1, allowed: true, remaining: 9},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, client := newTestRedis(t)
+			for i, step := range tt.steps {
+				now := testStart + step.at
+				got := runScript(t, client, bucketScript, []string{"rate_limit:test:token_bucket:v1"}, now, 10, window, step.n, "0")
+				if (got[0] == 1) != step.allowed || got[1] != step.remaining || got[3] != step.retryUs || got[4] != 0 {
+					t.Fatalf("step %d: got %v, want allowed=%v remaining=%d retry=%d", i, got, step.allowed, step.remaining, step.retryUs)
+				}
+			}
+		})
+	}
+}
+
+func TestBucketAdjustScript(t *testing.T) {
+	const window = int64(60_000_000)
+
+	tests := []struct {
+		name      string
+		charged   int
+		tokens    int
+		remaining int64
+	}{
+		{name: "refund", charged: 6, tokens: 4, remaining: 8},
+		{name: "refund is capped on the next refill", charged: 2, tokens: 5, remaining: 10},
+		{name: "extra charge", charged: 2, tokens: -3, remaining: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, client := newTestRedis(t)
+			keys := []string{"rate_limit:test:token_bucket:v1"}
+			runScript(t, client, bucketScript, keys, testStart, 10, window, tt.charged, "0")
+
+			if err := client.Eval(context.Background(), bucketAdjustScript, keys, tt.tokens).Err(); err != nil {
+				t.Fatal(err)
+			}
+
+			got := runScript(t, client, bucketScript, keys, testStart, 10, window, 0, "0")
+			if got[1] != tt.remaining {
+				t.Errorf("got remaining %d, want %d", got[1], tt.remaining)
+			}
+		})
+	}
+}
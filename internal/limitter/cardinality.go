@@ -0,0 +1,145 @@
+// internal/limitter/cardinality.go
+package limitter
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// DistinctLimiter limits the number of distinct values seen per key, e.g.
+// usernames tried per IP or resource IDs accessed per API key
+type DistinctLimiter interface {
+	IsAllowedDistinct(ctx context.Context, key, value string, limit int, window time.Duration) (*RateLimitResult, error)
+}
+
+// RedisDistinctLimiter implements DistinctLimiter with HyperLogLogs in Redis.
+// Counts are approximate (standard error of 0.81%).
+type RedisDistinctLimiter struct {
+	client RedisClient
+}
+
+// NewRedisDistinctLimiter creates a new Redis-based distinct-count limiter
+func NewRedisDistinctLimiter(client RedisClient) *RedisDistinctLimiter {
+	return &RedisDistinctLimiter{
+		client: client,
+	}
+}
+
+// IsAllowedDistinct checks whether value may be used by key. Values already
+// seen in the window are always allowed, new values are allowed while the
+// number of distinct values is below limit. Rejected values are not recorded.
+// Allowing a new value returns a reservation whose refund gives its slot back.
+func (d *RedisDistinctLimiter) IsAllowedDistinct(ctx context.Context, key, value string, limit int, window time.Duration) (*RateLimitResult, error) {
+	now := time.Now()
+	index := now.UnixNano() / int64(window)
+	hllKey := fmt.Sprintf("%s:%d", key, index)
+	refundKey := hllKey + ":refunds"
+	resetTime := time.Unix(0, (index+1)*int64(window))
+	ttl := time.Until(resetTime) + time.Minute
+
+	count, refunded, err := d.count(ctx, hllKey, refundKey)
+	if err != nil {
+		return nil, err
+	}
+	used := max(count-refunded, 0)
+
+	if used < int64(limit) {
+		// HyperLogLogs can't forget a value, so a refunded value stays in
+		// the set and takes its slot again when it is used
+		reused, err := d.client.ZRem(ctx, refundKey, value).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to reuse refunded value: %w", err)
+		}
+		if err := d.client.PFAdd(ctx, hllKey, value).Err(); err != nil {
+			return nil, fmt.Errorf("failed to add distinct value: %w", err)
+		}
+		d.client.Expire(ctx, hllKey, ttl)
+
+		newCount, refunded, err := d.count(ctx, hllKey, refundKey)
+		if err != nil {
+			return nil, err
+		}
+		result := distinctResult(true, max(newCount-refunded, 0), limit, resetTime)
+		if reused == 1 || newCount > count {
+			result.Reservation = newReservation(func(ctx context.Context) error {
+				if err := d.client.ZAdd(ctx, refundKey, 0, value).Err(); err != nil {
+					return fmt.Errorf("failed to refund distinct value: %w", err)
+				}
+				d.client.Expire(ctx, refundKey, ttl)
+				return nil
+			})
+		}
+		return result, nil
+	}
+
+	// At the limit only known values pass. Test membership on a scratch copy
+	// so that rejected values don't grow the set.
+	known, err := d.isKnown(ctx, hllKey, value)
+	if err != nil {
+		return nil, err
+	}
+	if known {
+		// A refunded value needs a free slot again
+		removed, err := d.client.ZRem(ctx, refundKey, value).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to check refunded value: %w", err)
+		}
+		if removed == 1 {
+			d.client.ZAdd(ctx, refundKey, 0, value)
+			known = false
+		}
+	}
+
+	return distinctResult(known, used, limit, resetTime), nil
+}
+
+// count returns the number of distinct values in the HyperLogLog at hllKey
+// and how many of them were refunded
+func (d *RedisDistinctLimiter) count(ctx context.Context, hllKey, refundKey string) (int64, int64, error) {
+	count, err := d.client.PFCount(ctx, hllKey).Result()
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to count distinct values: %w", err)
+	}
+	refunded, err := d.client.ZCard(ctx, refundKey).Result()
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to count refunded values: %w", err)
+	}
+	return count, refunded, nil
+}
+
+// isKnown reports whether value is (probably) already in the HyperLogLog at key
+func (d *RedisDistinctLimiter) isKnown(ctx context.Context, key, value string) (bool, error) {
+	scratchKey := fmt.Sprintf("%s:probe:%d", key, time.Now().UnixNano())
+	defer d.client.Del(ctx, scratchKey)
+
+	if err := d.client.PFMerge(ctx, scratchKey, key).Err(); err != nil {
+		return false, fmt.Errorf("failed to copy distinct values: %w", err)
+	}
+	d.client.Expire(ctx, scratchKey, time.Minute)
+
+	changed, err := d.client.PFAdd(ctx, scratchKey, value).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to probe distinct value: %w", err)
+	}
+	return changed == 0, nil
+}
+
+func distinctResult(allowed bool, count int64, limit int, resetTime time.Time) *RateLimitResult {
+	remaining := limit - int(count)
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	retryAfter := time.Duration(0)
+	if !allowed {
+		retryAfter = time.Until(resetTime)
+	}
+
+	return &RateLimitResult{
+		Allowed:    allowed,
+		Remaining:  remaining,
+		ResetTime:  resetTime,
+		RetryAfter: retryAfter,
+	}
+}
@@ -0,0 +1,81 @@
+// internal/limitter/cardinality_test.go
+package limitter
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestDistinctLimiter(t *testing.T) {
+	tests := []struct {
+		name      string
+		values    []string
+		allowed   []bool
+		remaining []int
+	}{
+		{
+			name:      "new values below the limit",
+			values:    []string{"a", "b", "c"},
+			allowed:   []bool{true, true, true},
+			remaining: []int{2, 1, 0},
+		},
+		{
+			name:      "repeated values take one slot",
+			values:    []string{"a", "a", "b", "a"},
+			allowed:   []bool{true, true, true, true},
+			remaining: []int{2, 2, 1, 1},
+		},
+		{
+			name:      "new values at the limit are rejected",
+			values:    []string{"a", "b", "c", "d", "e"},
+			allowed:   []bool{true, true, true, false, false},
+			remaining: []int{2, 1, 0, 0, 0},
+		},
+		{
+			name:      "known values pass at the limit",
+			values:    []string{"a", "b", "c", "d", "b"},
+			allowed:   []bool{true, true, true, false, true},
+			remaining: []int{2, 1, 0, 0, 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, client := newTestRedis(t)
+			limiter := NewRedisDistinctLimiter(client)
+			ctx := context.Background()
+
+			for i, value := range tt.values {
+				result, err := limiter.IsAllowedDistinct(ctx, "rate_limit:distinct:test", value, 3, time.Hour)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if result.Allowed != tt.allowed[i] || result.Remaining != tt.remaining[i] {
+					t.Errorf("value %q: got allowed %v remaining %d, want %v %d",
+						value, result.Allowed, result.Remaining, tt.allowed[i], tt.remaining[i])
+				}
+				if !result.Allowed && result.RetryAfter <= 0 {
+					t.Errorf("value %q: got no retry after for a rejection", value)
+				}
+			}
+		})
+	}
+}
+
+func TestDistinctLimiterLeavesNoProbes(t *testing.T) {
+	server, client := newTestRedis(t)
+	limiter := NewRedisDistinctLimiter(client)
+	ctx := context.Background()
+
+	for _, value := range []string{"a", "b", "c"} {
+		if _, err := limiter.IsAllowedDistinct(ctx, "rate_limit:distinct:test", value, 1, time.Hour); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Membership probes work on scratch copies that are removed again
+	if keys := server.Keys(); len(keys) != 1 {
+		t.Errorf("got keys %v, want only the window's HyperLogLog", keys)
+	}
+}
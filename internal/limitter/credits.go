@@ -0,0 +1,134 @@
+// internal/limitter/credits.go
+package limitter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// spendCreditsScript atomically decrements a balance if it covers the cost.
+// Returns {allowed, balance}.
+const spendCreditsScript = `
+local balance = tonumber(redis.call('GET', KEYS[1]) or '0')
+local cost = tonumber(ARGV[1])
+if balance < cost then
+	return {0, balance}
+end
+return {1, redis.call('DECRBY', KEYS[1], cost)}
+`
+
+// ErrInvalidAmount is returned for credit costs and top-ups that are not positive
+var ErrInvalidAmount = errors.New("limitter: credit amount must be greater than 0")
+
+// CreditConfig holds configuration for prepaid credit balances
+type CreditConfig struct {
+	// Balance at or below which a key is considered low on credits
+	LowBalanceThreshold int64
+	// Called when a spend takes a balance to or below LowBalanceThreshold
+	OnLowBalance func(key string, balance int64)
+	// Key prefix for Redis keys
+	KeyPrefix string
+}
+
+// CreditResult represents the result of spending credits
+type CreditResult struct {
+	Allowed    bool
+	Balance    int64
+	LowBalance bool
+	// Reservation returns the spent credits when refunded (nil if not allowed)
+	Reservation Reservation
+}
+
+// CreditStore keeps prepaid credit balances in Redis. Balances never reset,
+// they only change through spending and top-ups.
+type CreditStore struct {
+	client RedisClient
+	config CreditConfig
+}
+
+// NewCreditStore creates a new Redis-based credit store
+func NewCreditStore(client RedisClient, config CreditConfig) *CreditStore {
+	if config.KeyPrefix == "" {
+		config.KeyPrefix = "rate_limit:credits:"
+	}
+
+	return &CreditStore{
+		client: client,
+		config: config,
+	}
+}
+
+// Balance returns the current balance of key
+func (s *CreditStore) Balance(ctx context.Context, key string) (int64, error) {
+	val, err := s.client.Get(ctx, s.config.KeyPrefix+key).Result()
+	if err != nil {
+		if errors.Is(err, Nil) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to get balance: %w", err)
+	}
+
+	balance, err := strconv.ParseInt(val, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid balance %q: %w", val, err)
+	}
+	return balance, nil
+}
+
+// TopUp adds amount credits to key and returns the new balance
+func (s *CreditStore) TopUp(ctx context.Context, key string, amount int64) (int64, error) {
+	if amount <= 0 {
+		return 0, ErrInvalidAmount
+	}
+
+	balance, err := s.client.IncrBy(ctx, s.config.KeyPrefix+key, amount).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to top up credits: %w", err)
+	}
+	return balance, nil
+}
+
+// Spend atomically deducts cost credits from key if the balance covers it
+func (s *CreditStore) Spend(ctx context.Context, key string, cost int64) (*CreditResult, error) {
+	if cost <= 0 {
+		return nil, ErrInvalidAmount
+	}
+	creditKey := s.config.KeyPrefix + key
+
+	val, err := s.client.Eval(ctx, spendCreditsScript, []string{creditKey}, cost).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to spend credits: %w", err)
+	}
+
+	reply, ok := val.([]interface{})
+	if !ok || len(reply) != 2 {
+		return nil, fmt.Errorf("unexpected spend reply: %v", val)
+	}
+	allowed, _ := reply[0].(int64)
+	balance, _ := reply[1].(int64)
+
+	result := &CreditResult{
+		Allowed:    allowed == 1,
+		Balance:    balance,
+		LowBalance: balance <= s.config.LowBalanceThreshold,
+	}
+
+	if !result.Allowed {
+		return result, nil
+	}
+
+	// Notify once when the balance crosses the threshold
+	if result.LowBalance && balance+cost > s.config.LowBalanceThreshold && s.config.OnLowBalance != nil {
+		s.config.OnLowBalance(key, balance)
+	}
+
+	result.Reservation = newReservation(func(ctx context.Context) error {
+		if err := s.client.IncrBy(ctx, creditKey, cost).Err(); err != nil {
+			return fmt.Errorf("failed to refund credits: %w", err)
+		}
+		return nil
+	})
+	return result, nil
+}
@@ -0,0 +1,151 @@
+// internal/limitter/credits_test.go
+package limitter
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestCreditStoreSpend(t *testing.T) {
+	tests := []struct {
+		name    string
+		topUp   int64
+		costs   []int64
+		allowed []bool
+		balance []int64
+		low     []bool
+	}{
+		{
+			name:    "spend within the balance",
+			topUp:   10,
+			costs:   []int64{3, 3},
+			allowed: []bool{true, true},
+			balance: []int64{7, 4},
+			low:     []bool{false, true},
+		},
+		{
+			name:    "reject what the balance doesn't cover",
+			topUp:   6,
+			costs:   []int64{4, 3, 2},
+			allowed: []bool{true, false, true},
+			balance: []int64{2, 2, 0},
+			low:     []bool{true, true, true},
+		},
+		{
+			name:    "no balance",
+			costs:   []int64{1},
+			allowed: []bool{false},
+			balance: []int64{0},
+			low:     []bool{true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, client := newTestRedis(t)
+			var alerts []int64
+			store := NewCreditStore(client, CreditConfig{
+				LowBalanceThreshold: 5,
+				OnLowBalance:        func(key string, balance int64) { alerts = append(alerts, balance) },
+			})
+			ctx := context.Background()
+
+			if tt.topUp > 0 {
+				if _, err := store.TopUp(ctx, "api_key:test", tt.topUp); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			for i, cost := range tt.costs {
+				result, err := store.Spend(ctx, "api_key:test", cost)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if result.Allowed != tt.allowed[i] || result.Balance != tt.balance[i] || result.LowBalance != tt.low[i] {
+					t.Errorf("spend %d of %d: got allowed=%v balance=%d low=%v, want allowed=%v balance=%d low=%v",
+						i, cost, result.Allowed, result.Balance, result.LowBalance, tt.allowed[i], tt.balance[i], tt.low[i])
+				}
+				if (result.Reservation != nil) != result.Allowed {
+					t.Errorf("spend %d: got reservation %v for allowed=%v", i, result.Reservation, result.Allowed)
+				}
+			}
+
+			// The threshold is reported once, when a spend crosses it
+			if tt.topUp > 0 && len(alerts) != 1 {
+				t.Errorf("got low balance alerts %v, want one", alerts)
+			}
+		})
+	}
+}
+
+func TestCreditStoreRefund(t *testing.T) {
+	_, client := newTestRedis(t)
+	store := NewCreditStore(client, CreditConfig{})
+	ctx := context.Background()
+
+	if _, err := store.TopUp(ctx, "api_key:test", 10); err != nil {
+		t.Fatal(err)
+	}
+	result, err := store.Spend(ctx, "api_key:test", 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := result.Reservation.Refund(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// A second refund must not add the credits again
+	if err := result.Reservation.Refund(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	balance, err := store.Balance(ctx, "api_key:test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if balance != 10 {
+		t.Errorf("got balance %d, want 10", balance)
+	}
+}
+
+func TestCreditStoreRejectsInvalidAmounts(t *testing.T) {
+	tests := []struct {
+		name string
+		call func(*CreditStore) error
+	}{
+		{name: "zero cost", call: func(s *CreditStore) error {
+			_, err := s.Spend(context.Background(), "api_key:test", 0)
+			return err
+		}},
+		{name: "negative cost", call: func(s *CreditStore) error {
+			_, err := s.Spend(context.Background(), "api_key:test", -5)
+			return err
+		}},
+		{name: "zero top-up", call: func(s *CreditStore) error {
+			_, err := s.TopUp(context.Background(), "api_key:test", 0)
+			return err
+		}},
+		{name: "negative top-up", call: func(s *CreditStore) error {
+			_, err := s.TopUp(context.Background(), "api_key:test", -5)
+			return err
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, client := newTestRedis(t)
+			store := NewCreditStore(client, CreditConfig{})
+			if err := tt.call(store); !errors.Is(err, ErrInvalidAmount) {
+				t.Fatalf("got %v, want ErrInvalidAmount", err)
+			}
+
+			balance, err := store.Balance(context.Background(), "api_key:test")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if balance != 0 {
+				t.Errorf("got balance %d, want 0", balance)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+// internal/limitter/denials.go
+package limitter
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultDenialTTL is how long a denial is cached when no limit is given
+const DefaultDenialTTL = time.Second
+
+// DenialCache remembers denied keys in process, so retries can be rejected
+// without a round trip to the backend. The cache is local to each instance
+// and is not told when another instance resets a key, so entries are kept
+// for at most maxTTL even when the key resets later.
+type DenialCache struct {
+	maxTTL time.Duration
+
+	mu        sync.Mutex
+	entries   map[string]denial
+	lastPrune time.Time
+}
+
+// denial is a cached denial of one key
+type denial struct {
+	// When the key resets, reported to the client
+	until time.Time
+	// When the entry is dropped and the backend is asked again
+	expires time.Time
+}
+
+// NewDenialCache creates an empty denial cache that keeps each denial for at
+// most maxTTL (default DefaultDenialTTL)
+func NewDenialCache(maxTTL time.Duration) *DenialCache {
+	if maxTTL <= 0 {
+		maxTTL = DefaultDenialTTL
+	}
+
+	return &DenialCache{
+		maxTTL:    maxTTL,
+		entries:   make(map[string]denial),
+		lastPrune: time.Now(),
+	}
+}
+
+// Denied reports whether key is still denied and until when
+func (c *DenialCache) Denied(key string) (time.Time, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return time.Time{}, false
+	}
+	if !time.Now().Before(entry.expires) {
+		delete(c.entries, key)
+		return time.Time{}, false
+	}
+	return entry.until, true
+}
+
+// Deny records that key is denied until the given time
+func (c *DenialCache) Deny(key string, until time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if !now.Before(until) {
+		return
+	}
+
+	// Drop expired entries now and then
+	if now.Sub(c.lastPrune) > time.Minute {
+		for k, entry := range c.entries {
+			if !now.Before(entry.expires) {
+				delete(c.entries, k)
+			}
+		}
+		c.lastPrune = now
+	}
+
+	expires := now.Add(c.maxTTL)
+	if until.Before(expires) {
+		expires = until
+	}
+	c.entries[key] = denial{until: until, expires: expires}
+}
+
+// Invalidate forgets a cached denial, e.g. after an admin resets the key
+func (c *DenialCache) Invalidate(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, key)
+}
@@ -0,0 +1,39 @@
+// internal/limitter/denials_test.go
+package limitter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDenialCacheCapsTTL(t *testing.T) {
+	tests := []struct {
+		name   string
+		maxTTL time.Duration
+		reset  time.Duration
+		after  time.Duration
+		denied bool
+	}{
+		{name: "denied before the cap", maxTTL: 50 * time.Millisecond, reset: time.Hour, after: 0, denied: true},
+		{name: "asked again after the cap", maxTTL: 20 * time.Millisecond, reset: time.Hour, after: 40 * time.Millisecond, denied: false},
+		{name: "asked again after the reset", maxTTL: time.Hour, reset: 20 * time.Millisecond, after: 40 * time.Millisecond, denied: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := NewDenialCache(tt.maxTTL)
+			reset := time.Now().Add(tt.reset)
+			cache.Deny("rate_limit:test", reset)
+			time.Sleep(tt.after)
+
+			until, denied := cache.Denied("rate_limit:test")
+			if denied != tt.denied {
+				t.Fatalf("got denied=%v, want %v", denied, tt.denied)
+			}
+			// Clients are told when the key really resets, not when the entry expires
+			if denied && !until.Equal(reset) {
+				t.Errorf("got denied until %v, want %v", until, reset)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+// internal/limitter/early.go
+package limitter
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"time"
+)
+
+// EarlyThrottleConfig holds configuration for probabilistic early throttling
+type EarlyThrottleConfig struct {
+	// Fraction of the limit after which requests may be rejected early (default 0.8)
+	Threshold float64
+	// Random source returning values in [0, 1), defaults to math/rand
+	Rand func() float64
+}
+
+// EarlyThrottler is a RED-style decision stage on top of any RateLimiter.
+// Once a key has used more than Threshold of its limit, allowed requests are
+// rejected with a probability growing linearly from 0 at the threshold to 1
+// at the limit, which smooths traffic around window edges.
+type EarlyThrottler struct {
+	limiter RateLimiter
+	config  EarlyThrottleConfig
+}
+
+// NewEarlyThrottler wraps limiter with probabilistic early throttling
+func NewEarlyThrottler(limiter RateLimiter, config EarlyThrottleConfig) *EarlyThrottler {
+	if config.Threshold <= 0 || config.Threshold >= 1 {
+		config.Threshold = 0.8
+	}
+	if config.Rand == nil {
+		config.Rand = rand.Float64
+	}
+
+	return &EarlyThrottler{
+		limiter: limiter,
+		config:  config,
+	}
+}
+
+// IsAllowed checks the wrapped limiter and may reject early near the limit
+func (e *EarlyThrottler) IsAllowed(ctx context.Context, key string, limit int, window time.Duration) (*RateLimitResult, error) {
+	result, err := e.limiter.IsAllowed(ctx, key, limit, window)
+	if err != nil {
+		return nil, err
+	}
+	return e.decide(ctx, result, limit, window, 1), nil
+}
+
+// IsAllowedN implements CostLimiter when the wrapped limiter does
+func (e *EarlyThrottler) IsAllowedN(ctx context.Context, key string, limit int, window time.Duration, n int) (*RateLimitResult, error) {
+	costLimiter, ok := e.limiter.(CostLimiter)
+	if !ok {
+		return e.IsAllowed(ctx, key, limit, window)
+	}
+
+	result, err := costLimiter.IsAllowedN(ctx, key, limit, window, n)
+	if err != nil {
+		return nil, err
+	}
+	return e.decide(ctx, result, limit, window, n), nil
+}
+
+// Adjust implements CostLimiter when the wrapped limiter does, and returns
+// ErrAdjustUnsupported otherwise
+func (e *EarlyThrottler) Adjust(ctx context.Context, key string, window time.Duration, delta int) error {
+	costLimiter, ok := e.limiter.(CostLimiter)
+	if !ok {
+		return ErrAdjustUnsupported
+	}
+	return costLimiter.Adjust(ctx, key, window, delta)
+}
+
+// Peek implements Peeker when the wrapped limiter does, and returns
+// ErrPeekUnsupported otherwise. Peeks are never rejected early.
+func (e *EarlyThrottler) Peek(ctx context.Context, key string, limit int, window time.Duration) (*RateLimitResult, error) {
+	peeker, ok := e.limiter.(Peeker)
+	if !ok {
+		return nil, ErrPeekUnsupported
+	}
+	return peeker.Peek(ctx, key, limit, window)
+}
+
+// Reset implements Resetter when the wrapped limiter does
+func (e *EarlyThrottler) Reset(ctx context.Context, key string) error {
+	resetter, ok := e.limiter.(Resetter)
+	if !ok {
+		return fmt.Errorf("limiter does not support reset")
+	}
+	return resetter.Reset(ctx, key)
+}
+
+// decide applies the early rejection probability to an allowed result of a
+// request costing n, based on the usage before the request
+func (e *EarlyThrottler) decide(ctx context.Context, result *RateLimitResult, limit int, window time.Duration, n int) *RateLimitResult {
+	if !result.Allowed || limit <= 0 {
+		return result
+	}
+
+	used := float64(limit-result.Remaining-n) / float64(limit)
+	if used <= e.config.Threshold {
+		return result
+	}
+
+	probability := (used - e.config.Threshold) / (1 - e.config.Threshold)
+	if e.config.Rand() >= probability {
+		return result
+	}
+
+	// The rejected request should not use up quota
+	remaining := result.Remaining
+	if result.Reservation != nil && result.Reservation.Refund(ctx) == nil {
+		remaining = min(remaining+n, limit)
+	}
+
+	// Roughly the time until one more request fits in the window
+	retryAfter := window / time.Duration(limit)
+	if retryAfter < time.Second {
+		retryAfter = time.Second
+	}
+
+	return &RateLimitResult{
+		Allowed:    false,
+		Remaining:  remaining,
+		ResetTime:  result.ResetTime,
+		RetryAfter: retryAfter,
+		Early:      true,
+	}
+}
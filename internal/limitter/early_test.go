@@ -0,0 +1,75 @@
+// internal/limitter/early_test.go
+package limitter
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestEarlyThrottle(t *testing.T) {
+	tests := []struct {
+		name string
+		// rand is the value drawn for every decision
+		rand      float64
+		costs     []int
+		allowed   []bool
+		early     []bool
+		remaining []int
+	}{
+		{
+			name:      "rejected once usage before the request passes the threshold",
+			costs:     []int{1, 1, 1, 1, 1, 1, 1},
+			allowed:   []bool{true, true, true, true, true, true, false},
+			early:     []bool{false, false, false, false, false, false, true},
+			remaining: []int{9, 8, 7, 6, 5, 4, 4},
+		},
+		{
+			name:      "a request crossing the threshold is not rejected",
+			costs:     []int{5, 1},
+			allowed:   []bool{true, true},
+			early:     []bool{false, false},
+			remaining: []int{5, 4},
+		},
+		{
+			name:      "rejections grow likelier towards the limit",
+			rand:      0.5,
+			costs:     []int{6, 1, 1, 1},
+			allowed:   []bool{true, true, true, false},
+			early:     []bool{false, false, false, true},
+			remaining: []int{4, 3, 2, 2},
+		},
+		{
+			name:      "rejections at the limit are not early",
+			rand:      0.99,
+			costs:     []int{10, 1},
+			allowed:   []bool{true, false},
+			early:     []bool{false, false},
+			remaining: []int{0, 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			throttler := NewEarlyThrottler(NewMemoryRateLimiter(), EarlyThrottleConfig{
+				Threshold: 0.5,
+				Rand:      func() float64 { return tt.rand },
+			})
+			ctx := context.Background()
+
+			for i, cost := range tt.costs {
+				result, err := throttler.IsAllowedN(ctx, "rate_limit:test", 10, time.Minute, cost)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if result.Allowed != tt.allowed[i] || result.Early != tt.early[i] || result.Remaining != tt.remaining[i] {
+					t.Errorf("request %d: got allowed=%v early=%v remaining=%d, want allowed=%v early=%v remaining=%d",
+						i+1, result.Allowed, result.Early, result.Remaining, tt.allowed[i], tt.early[i], tt.remaining[i])
+				}
+				if result.Early && result.RetryAfter <= 0 {
+					t.Errorf("request %d: got no retry after for an early rejection", i+1)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,417 @@
+// internal/limitter/export.go
+package limitter
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ErrUnsupportedState is returned when a backend can't hold a kind of state
+var ErrUnsupportedState = errors.New("limitter: state not supported by backend")
+
+// KeyState is the portable state of one limiter key
+type KeyState struct {
+	Key string `json:"key"`
+	// Algorithm is "sliding_log", "gcra", "token_bucket", "regional",
+	// "counter" (fixed windows and leases), "hyperloglog" or
+	// "distinct_refunds". Exports before these were told apart use "hash".
+	Algorithm string `json:"algorithm"`
+	// Members of a sliding log scored by timestamp in Unix nanoseconds, or
+	// the refunded values of a distinct-value limit
+	Members []ScoredMember `json:"members,omitempty"`
+	// Value of a counter or HyperLogLog, base64 encoded when Binary is set
+	Value  string `json:"value,omitempty"`
+	Binary bool   `json:"binary,omitempty"`
+	// Fields of a GCRA, token bucket or per-region counter hash
+	Fields map[string]string `json:"fields,omitempty"`
+	// WindowMs is the window the state was recorded with, 0 if unknown
+	WindowMs int64 `json:"window_ms,omitempty"`
+	// TTLMs is the remaining time to live, 0 if the key doesn't expire
+	TTLMs int64 `json:"ttl_ms,omitempty"`
+}
+
+// ScoredMember is one member of a sorted set
+type ScoredMember struct {
+	Member string  `json:"member"`
+	Score  float64 `json:"score"`
+}
+
+// StateStore is implemented by backends whose state can be exported and imported
+type StateStore interface {
+	// ExportState calls fn with the state of every key starting with prefix
+	ExportState(ctx context.Context, prefix string, fn func(*KeyState) error) error
+	// ImportState replaces the state of state.Key
+	ImportState(ctx context.Context, state *KeyState) error
+}
+
+// ExportStates writes the state of every key starting with prefix to w as
+// newline-delimited JSON. Returns the number of keys written.
+func ExportStates(ctx context.Context, store StateStore, prefix string, w io.Writer) (int, error) {
+	enc := json.NewEncoder(w)
+	count := 0
+	err := store.ExportState(ctx, prefix, func(state *KeyState) error {
+		if err := enc.Encode(state); err != nil {
+			return fmt.Errorf("failed to write state: %w", err)
+		}
+		count++
+		return nil
+	})
+	return count, err
+}
+
+// ImportStates reads newline-delimited JSON written by ExportStates into
+// store. States the store can't hold are skipped. Returns the number of keys
+// imported and skipped.
+func ImportStates(ctx context.Context, store StateStore, r io.Reader) (int, int, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+
+	imported, skipped := 0, 0
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var state KeyState
+		if err := json.Unmarshal([]byte(line), &state); err != nil {
+			return imported, skipped, fmt.Errorf("invalid state on line %d: %w", imported+skipped+1, err)
+		}
+
+		if err := store.ImportState(ctx, &state); err != nil {
+			if errors.Is(err, ErrUnsupportedState) {
+				skipped++
+				continue
+			}
+			return imported, skipped, err
+		}
+		imported++
+	}
+	if err := scanner.Err(); err != nil {
+		return imported, skipped, fmt.Errorf("failed to read states: %w", err)
+	}
+	return imported, skipped, nil
+}
+
+// nonLimiterPrefixes are the default prefixes of keys under "rate_limit:"
+// that hold other state than rate limits. They are never exported.
+var nonLimiterPrefixes = []string{
+	"rate_limit:ban:",
+	"rate_limit:violations:",
+	"rate_limit:offenses:",
+	"rate_limit:bruteforce:",
+	"rate_limit:credits:",
+	"rate_limit:top:",
+	"rate_limit:overage:",
+	"rate_limit:idempotency:",
+}
+
+var (
+	// logMemberPattern matches the members sliding logs write
+	logMemberPattern = regexp.MustCompile(`^[0-9-]+(:[0-9]+)?$`)
+	// regionalKeyPattern matches the per-window keys of RegionalRateLimiter
+	regionalKeyPattern = regexp.MustCompile(`:g:[0-9]+$`)
+)
+
+// RedisStateStore exports and imports limiter state in Redis. Keys are
+// recognized by their format; keys of bans, credits, reports and other
+// bookkeeping are skipped.
+type RedisStateStore struct {
+	client RedisClient
+}
+
+// NewRedisStateStore creates a state store for the keys in client
+func NewRedisStateStore(client RedisClient) *RedisStateStore {
+	return &RedisStateStore{client: client}
+}
+
+// ExportState walks the keys starting with prefix with SCAN
+func (s *RedisStateStore) ExportState(ctx context.Context, prefix string, fn func(*KeyState) error) error {
+	seen := make(map[string]bool)
+	var cursor uint64
+	for {
+		keys, next, err := s.client.Scan(ctx, cursor, prefix+"*", 100).Result()
+		if err != nil {
+			return fmt.Errorf("failed to scan keys: %w", err)
+		}
+
+		for _, key := range keys {
+			// SCAN may return a key more than once
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			state, err := s.readState(ctx, key)
+			if err != nil {
+				return err
+			}
+			if state == nil {
+				continue
+			}
+			if err := fn(state); err != nil {
+				return err
+			}
+		}
+
+		cursor = next
+		if cursor == 0 {
+			return nil
+		}
+	}
+}
+
+// readState reads one key, returning nil if it disappeared or is not the
+// state of a limiter
+func (s *RedisStateStore) readState(ctx context.Context, key string) (*KeyState, error) {
+	if !isLimiterKey(key) {
+		return nil, nil
+	}
+
+	keyType, err := s.client.Type(ctx, key).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get type of %s: %w", key, err)
+	}
+
+	state := &KeyState{Key: key}
+	switch keyType {
+	case "zset":
+		flat, err := s.client.ZRange(ctx, key, 0, -1, "WITHSCORES").Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", key, err)
+		}
+		state.Algorithm = "sliding_log"
+		if strings.HasSuffix(key, ":refunds") {
+			state.Algorithm = "distinct_refunds"
+		}
+		for i := 0; i+1 < len(flat); i += 2 {
+			if state.Algorithm == "sliding_log" && !logMemberPattern.MatchString(flat[i]) {
+				return nil, nil
+			}
+			score, err := strconv.ParseFloat(flat[i+1], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid score in %s: %w", key, err)
+			}
+			state.Members = append(state.Members, ScoredMember{Member: flat[i], Score: score})
+		}
+	case "string":
+		value, err := s.client.Get(ctx, key).Result()
+		if err != nil {
+			if errors.Is(err, Nil) {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("failed to read %s: %w", key, err)
+		}
+		switch {
+		case strings.HasPrefix(value, "HYLL"):
+			state.Algorithm = "hyperloglog"
+		case isCounter(value):
+			state.Algorithm = "counter"
+		default:
+			return nil, nil
+		}
+		state.Value = value
+		if !utf8.ValidString(value) {
+			state.Value = base64.StdEncoding.EncodeToString([]byte(value))
+			state.Binary = true
+		}
+	case "hash":
+		fields, err := s.client.HGetAll(ctx, key).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", key, err)
+		}
+		state.Algorithm = hashAlgorithm(key, fields)
+		if state.Algorithm == "" {
+			return nil, nil
+		}
+		state.Fields = fields
+	default:
+		return nil, nil
+	}
+
+	ttl, err := s.client.TTL(ctx, key).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get TTL of %s: %w", key, err)
+	}
+	if ttl > 0 {
+		state.TTLMs = ttl.Milliseconds()
+	}
+	return state, nil
+}
+
+// isLimiterKey reports whether key may hold the state of a limiter
+func isLimiterKey(key string) bool {
+	for _, prefix := range nonLimiterPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return false
+		}
+	}
+	// Scratch copies made while probing distinct values
+	return !strings.Contains(key, ":probe:")
+}
+
+// isCounter reports whether value is a counter written by INCRBY
+func isCounter(value string) bool {
+	_, err := strconv.ParseInt(value, 10, 64)
+	return err == nil
+}
+
+// hashAlgorithm returns the algorithm whose state the hash at key holds, or
+// "" if no limiter writes such a hash
+func hashAlgorithm(key string, fields map[string]string) string {
+	switch {
+	case strings.HasSuffix(key, VersionedKey("", AlgorithmGCRA)) && fields["tat"] != "":
+		return "gcra"
+	case strings.HasSuffix(key, VersionedKey("", AlgorithmTokenBucket)) && fields["tokens"] != "":
+		return "token_bucket"
+	case regionalKeyPattern.MatchString(key):
+		return "regional"
+	}
+	return ""
+}
+
+// ImportState replaces key with the given state
+func (s *RedisStateStore) ImportState(ctx context.Context, state *KeyState) error {
+	switch state.Algorithm {
+	case "sliding_log", "distinct_refunds", "counter", "hyperloglog", "gcra", "token_bucket", "regional", "hash":
+	default:
+		return fmt.Errorf("%w: %s", ErrUnsupportedState, state.Algorithm)
+	}
+
+	ttl := time.Duration(state.TTLMs) * time.Millisecond
+	if err := s.client.Del(ctx, state.Key).Err(); err != nil {
+		return fmt.Errorf("failed to clear %s: %w", state.Key, err)
+	}
+
+	switch state.Algorithm {
+	case "sliding_log", "distinct_refunds":
+		if len(state.Members) == 0 {
+			return nil
+		}
+		pipe := s.client.Pipeline()
+		for _, m := range state.Members {
+			pipe.ZAdd(ctx, state.Key, m.Score, m.Member)
+		}
+		if ttl > 0 {
+			pipe.Expire(ctx, state.Key, ttl)
+		}
+		if _, err := pipe.Exec(ctx); err != nil {
+			return fmt.Errorf("failed to import %s: %w", state.Key, err)
+		}
+	case "counter", "hyperloglog":
+		value := state.Value
+		if state.Binary {
+			raw, err := base64.StdEncoding.DecodeString(value)
+			if err != nil {
+				return fmt.Errorf("invalid value for %s: %w", state.Key, err)
+			}
+			value = string(raw)
+		}
+		if err := s.client.Set(ctx, state.Key, value, ttl).Err(); err != nil {
+			return fmt.Errorf("failed to import %s: %w", state.Key, err)
+		}
+	default:
+		if len(state.Fields) == 0 {
+			return nil
+		}
+		values := make([]interface{}, 0, len(state.Fields)*2)
+		for field, value := range state.Fields {
+			values = append(values, field, value)
+		}
+		if err := s.client.HSet(ctx, state.Key, values...).Err(); err != nil {
+			return fmt.Errorf("failed to import %s: %w", state.Key, err)
+		}
+		if ttl > 0 {
+			// Without a TTL the hash would outlive its window forever
+			if err := s.client.Expire(ctx, state.Key, ttl).Err(); err != nil {
+				s.client.Del(ctx, state.Key)
+				return fmt.Errorf("failed to set TTL of %s: %w", state.Key, err)
+			}
+		}
+	}
+	return nil
+}
+
+// ExportState calls fn with the request log of every key starting with prefix
+func (m *MemoryRateLimiter) ExportState(ctx context.Context, prefix string, fn func(*KeyState) error) error {
+	now := time.Now()
+
+	m.mu.Lock()
+	states := make([]*KeyState, 0, len(m.logs))
+	for key, log := range m.logs {
+		if !strings.HasPrefix(key, prefix) || !now.Before(log.expires) {
+			continue
+		}
+
+		state := &KeyState{
+			Key:       key,
+			Algorithm: "sliding_log",
+			WindowMs:  log.window.Milliseconds(),
+			TTLMs:     log.expires.Sub(now).Milliseconds(),
+		}
+		for _, e := range log.entries {
+			state.Members = append(state.Members, ScoredMember{
+				Member: logMember(time.Unix(0, e.at), e.n),
+				Score:  float64(e.at),
+			})
+		}
+		states = append(states, state)
+	}
+	m.mu.Unlock()
+
+	for _, state := range states {
+		if err := fn(state); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ImportState replaces the request log of state.Key. Only sliding logs are
+// supported.
+func (m *MemoryRateLimiter) ImportState(ctx context.Context, state *KeyState) error {
+	if state.Algorithm != "sliding_log" {
+		return fmt.Errorf("%w: %s", ErrUnsupportedState, state.Algorithm)
+	}
+
+	members := sortedByScore(state.Members)
+	window := time.Duration(state.WindowMs) * time.Millisecond
+	ttl := time.Duration(state.TTLMs) * time.Millisecond
+	if window <= 0 {
+		// Logs exported from Redis don't know their window, but their TTL
+		// still covers the entries in it
+		window = ttl
+	}
+	if ttl <= 0 {
+		ttl = window
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	log := &memoryLog{window: window, expires: time.Now().Add(ttl)}
+	for _, member := range members {
+		m.nextID++
+		log.entries = append(log.entries, memoryEntry{at: int64(member.Score), id: m.nextID, n: logCost(member.Member)})
+	}
+	m.logs[state.Key] = log
+	return nil
+}
+
+// sortedByScore returns a copy of members ordered by score
+func sortedByScore(members []ScoredMember) []ScoredMember {
+	sorted := append([]ScoredMember(nil), members...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Score < sorted[j].Score })
+	return sorted
+}
@@ -0,0 +1,298 @@
+// internal/limitter/export_test.go
+package limitter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"reflect"
+	"slices"
+	"sort"
+	"testing"
+	"time"
+)
+
+// exportAll returns the states store exports under "rate_limit:", ordered by key
+func exportAll(t *testing.T, store StateStore) []*KeyState {
+	t.Helper()
+	var states []*KeyState
+	err := store.ExportState(context.Background(), "rate_limit:", func(state *KeyState) error {
+		states = append(states, state)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].Key < states[j].Key })
+	return states
+}
+
+func TestRedisStateRoundTrip(t *testing.T) {
+	tests := []struct {
+		name       string
+		write      func(ctx context.Context, client RedisClient) error
+		algorithms []string
+		// limiter reads the state back after the import (optional)
+		limiter   func(client RedisClient) CostLimiter
+		remaining int
+	}{
+		{
+			name: "sliding log",
+			write: func(ctx context.Context, client RedisClient) error {
+				_, err := NewRedisRateLimiter(client, &Config{}).IsAllowedN(ctx, "rate_limit:test", 10, time.Minute, 3)
+				return err
+			},
+			algorithms: []string{"sliding_log"},
+			limiter:    func(client RedisClient) CostLimiter { return NewRedisRateLimiter(client, &Config{}) },
+			remaining:  7,
+		},
+		{
+			name: "gcra",
+			write: func(ctx context.Context, client RedisClient) error {
+				_, err := NewGCRARateLimiter(client).IsAllowedN(ctx, "rate_limit:test", 10, time.Minute, 3)
+				return err
+			},
+			algorithms: []string{"gcra"},
+			limiter:    func(client RedisClient) CostLimiter { return NewGCRARateLimiter(client) },
+			remaining:  7,
+		},
+		{
+			name: "token bucket",
+			write: func(ctx context.Context, client RedisClient) error {
+				_, err := NewBucketRateLimiter(client).IsAllowedN(ctx, "rate_limit:test", 10, time.Minute, 3)
+				return err
+			},
+			algorithms: []string{"token_bucket"},
+			limiter:    func(client RedisClient) CostLimiter { return NewBucketRateLimiter(client) },
+			remaining:  7,
+		},
+		{
+			name: "regional counters",
+			write: func(ctx context.Context, client RedisClient) error {
+				_, err := NewRegionalRateLimiter(client, RegionalConfig{Region: "eu"}).IsAllowedN(ctx, "rate_limit:test", 10, time.Minute, 3)
+				return err
+			},
+			algorithms: []string{"regional"},
+			limiter: func(client RedisClient) CostLimiter {
+				return NewRegionalRateLimiter(client, RegionalConfig{Region: "us"})
+			},
+			remaining: 7,
+		},
+		{
+			name: "hybrid lease",
+			write: func(ctx context.Context, client RedisClient) error {
+				_, err := NewHybridRateLimiter(client, HybridConfig{MaxError: 0.1}).IsAllowed(ctx, "rate_limit:test", 100, time.Hour)
+				return err
+			},
+			algorithms: []string{"counter"},
+		},
+		{
+			name: "distinct values with a refund",
+			write: func(ctx context.Context, client RedisClient) error {
+				distinct := NewRedisDistinctLimiter(client)
+				if _, err := distinct.IsAllowedDistinct(ctx, "rate_limit:distinct:test", "a", 5, time.Minute); err != nil {
+					return err
+				}
+				result, err := distinct.IsAllowedDistinct(ctx, "rate_limit:distinct:test", "b", 5, time.Minute)
+				if err != nil {
+					return err
+				}
+				return result.Reservation.Refund(ctx)
+			},
+			// miniredis keeps HyperLogLogs in a type of its own instead of
+			// strings, so only the refunds show up here
+			algorithms: []string{"distinct_refunds"},
+		},
+		{
+			name: "hyperloglog written by Redis",
+			write: func(ctx context.Context, client RedisClient) error {
+				return client.Set(ctx, "rate_limit:distinct:test:42", "HYLL\x01\x00\x00\x00\xff\xfe", time.Minute).Err()
+			},
+			algorithms: []string{"hyperloglog"},
+		},
+		{
+			name: "unknown strings are skipped",
+			write: func(ctx context.Context, client RedisClient) error {
+				return client.Set(ctx, "rate_limit:note", "hello", time.Minute).Err()
+			},
+		},
+		{
+			name: "sorted sets that aren't logs are skipped",
+			write: func(ctx context.Context, client RedisClient) error {
+				return client.ZAdd(ctx, "rate_limit:leaderboard", 3, "user:alice").Err()
+			},
+		},
+		{
+			name: "credits are skipped",
+			write: func(ctx context.Context, client RedisClient) error {
+				_, err := NewCreditStore(client, CreditConfig{}).TopUp(ctx, "api_key:test", 100)
+				return err
+			},
+		},
+		{
+			name: "bans are skipped",
+			write: func(ctx context.Context, client RedisClient) error {
+				_, err := NewBanManager(client, BanConfig{Threshold: 1}).RecordViolation(ctx, "ip:203.0.113.7")
+				return err
+			},
+		},
+		{
+			name: "idempotency keys are skipped",
+			write: func(ctx context.Context, client RedisClient) error {
+				_, err := NewIdempotencyTracker(client, time.Hour).Remember(ctx, "ip:203.0.113.7", "key-1", "fingerprint")
+				return err
+			},
+		},
+		{
+			name: "heavy hitters are skipped",
+			write: func(ctx context.Context, client RedisClient) error {
+				return NewHeavyHitterTracker(client, HeavyHitterConfig{}).Record(ctx, "api_v1", "ip:203.0.113.7", 1)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			_, sourceClient := newTestRedis(t)
+			_, targetClient := newTestRedis(t)
+			source := NewRedisStateStore(sourceClient)
+			target := NewRedisStateStore(targetClient)
+
+			if err := tt.write(ctx, sourceClient); err != nil {
+				t.Fatal(err)
+			}
+
+			var buf bytes.Buffer
+			exported, err := ExportStates(ctx, source, "rate_limit:", &buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := exportAll(t, source)
+			var algorithms []string
+			for _, state := range want {
+				algorithms = append(algorithms, state.Algorithm)
+			}
+			slices.Sort(algorithms)
+			if exported != len(tt.algorithms) || !slices.Equal(algorithms, tt.algorithms) {
+				t.Fatalf("exported %d keys as %v, want %v", exported, algorithms, tt.algorithms)
+			}
+
+			imported, skipped, err := ImportStates(ctx, target, &buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if imported != exported || skipped != 0 {
+				t.Fatalf("got %d imported and %d skipped, want %d imported", imported, skipped, exported)
+			}
+
+			got := exportAll(t, target)
+			if len(got) != len(want) {
+				t.Fatalf("got %d keys after import, want %d", len(got), len(want))
+			}
+			for i := range want {
+				if (got[i].TTLMs > 0) != (want[i].TTLMs > 0) {
+					t.Errorf("%s: got TTL %dms, want a TTL like the source's %dms", got[i].Key, got[i].TTLMs, want[i].TTLMs)
+				}
+				got[i].TTLMs, want[i].TTLMs = 0, 0
+				if !reflect.DeepEqual(got[i], want[i]) {
+					gotJSON, _ := json.Marshal(got[i])
+					wantJSON, _ := json.Marshal(want[i])
+					t.Errorf("got %s, want %s", gotJSON, wantJSON)
+				}
+			}
+
+			if tt.limiter != nil {
+				if got := remaining(t, tt.limiter(targetClient), "rate_limit:test", 10); got != tt.remaining {
+					t.Errorf("got remaining %d after import, want %d", got, tt.remaining)
+				}
+			}
+		})
+	}
+}
+
+func TestImportStateIntoMemory(t *testing.T) {
+	tests := []struct {
+		name      string
+		write     func(ctx context.Context, client RedisClient, memory *MemoryRateLimiter) error
+		fromRedis bool
+		imported  int
+		skipped   int
+		remaining int
+	}{
+		{
+			name: "memory to memory keeps the window",
+			write: func(ctx context.Context, client RedisClient, memory *MemoryRateLimiter) error {
+				_, err := memory.IsAllowedN(ctx, "rate_limit:test", 10, time.Minute, 4)
+				return err
+			},
+			imported:  1,
+			remaining: 6,
+		},
+		{
+			name: "redis sliding log",
+			write: func(ctx context.Context, client RedisClient, memory *MemoryRateLimiter) error {
+				_, err := NewRedisRateLimiter(client, &Config{}).IsAllowedN(ctx, "rate_limit:test", 10, time.Minute, 4)
+				return err
+			},
+			fromRedis: true,
+			imported:  1,
+			remaining: 6,
+		},
+		{
+			name: "gcra is not supported in memory",
+			write: func(ctx context.Context, client RedisClient, memory *MemoryRateLimiter) error {
+				_, err := NewGCRARateLimiter(client).IsAllowedN(ctx, "rate_limit:test", 10, time.Minute, 4)
+				return err
+			},
+			fromRedis: true,
+			skipped:   1,
+			remaining: 10,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			_, client := newTestRedis(t)
+			memory := NewMemoryRateLimiter()
+			if err := tt.write(ctx, client, memory); err != nil {
+				t.Fatal(err)
+			}
+
+			var source StateStore = memory
+			if tt.fromRedis {
+				source = NewRedisStateStore(client)
+			}
+			var buf bytes.Buffer
+			if _, err := ExportStates(ctx, source, "rate_limit:", &buf); err != nil {
+				t.Fatal(err)
+			}
+
+			target := NewMemoryRateLimiter()
+			imported, skipped, err := ImportStates(ctx, target, &buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if imported != tt.imported || skipped != tt.skipped {
+				t.Fatalf("got %d imported and %d skipped, want %d and %d", imported, skipped, tt.imported, tt.skipped)
+			}
+
+			result, err := target.Peek(ctx, "rate_limit:test", 10, time.Minute)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Remaining != tt.remaining {
+				t.Errorf("got remaining %d, want %d", result.Remaining, tt.remaining)
+			}
+
+			// The window is carried over when the source knows it
+			if !tt.fromRedis && tt.imported > 0 {
+				states := exportAll(t, target)
+				if len(states) != 1 || states[0].WindowMs != time.Minute.Milliseconds() {
+					t.Errorf("got states %+v, want one with a window of 1m", states)
+				}
+			}
+		})
+	}
+}
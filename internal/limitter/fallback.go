@@ -0,0 +1,225 @@
+// internal/limitter/fallback.go
+package limitter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// FallbackConfig holds configuration for the degraded mode
+type FallbackConfig struct {
+	// Expected number of instances sharing the limit. Local decisions use
+	// limit / Instances (default 1).
+	Instances int
+	// How long to decide locally before trying the primary again (default 5s)
+	RetryInterval time.Duration
+	// Called on every switch to and from local decisions (optional)
+	OnSwitch func(FallbackEvent)
+	// PassOverruns returns the error of a request whose caller deadline
+	// passed before the primary answered, so the caller can apply its own
+	// decision. Otherwise the request is decided locally. Overruns never
+	// switch to local decisions.
+	PassOverruns bool
+}
+
+// FallbackEvent describes a switch between the primary and local limiter
+type FallbackEvent struct {
+	// Degraded is true when switching to local decisions
+	Degraded bool
+	// Err is the primary error that caused the switch (nil when recovering)
+	Err error
+	At  time.Time
+}
+
+// FallbackStats counts the decisions and switches of a FallbackRateLimiter
+type FallbackStats struct {
+	Degraded         bool      `json:"degraded"`
+	Switches         int64     `json:"switches"`
+	PrimaryErrors    int64     `json:"primary_errors"`
+	Overruns         int64     `json:"overruns"`
+	LocalDecisions   int64     `json:"local_decisions"`
+	PrimaryDecisions int64     `json:"primary_decisions"`
+	LastSwitch       time.Time `json:"last_switch"`
+}
+
+// FallbackRateLimiter decides with the primary limiter while it works, and
+// with a local in-memory limiter while it errors or times out. The primary
+// is retried every RetryInterval and used again as soon as it recovers.
+type FallbackRateLimiter struct {
+	primary RateLimiter
+	local   *MemoryRateLimiter
+	config  FallbackConfig
+
+	mu        sync.Mutex
+	stats     FallbackStats
+	nextProbe time.Time
+}
+
+// NewFallbackRateLimiter wraps primary with a local fallback
+func NewFallbackRateLimiter(primary RateLimiter, config FallbackConfig) *FallbackRateLimiter {
+	if config.Instances <= 0 {
+		config.Instances = 1
+	}
+	if config.RetryInterval <= 0 {
+		config.RetryInterval = 5 * time.Second
+	}
+
+	return &FallbackRateLimiter{
+		primary: primary,
+		local:   NewMemoryRateLimiter(),
+		config:  config,
+	}
+}
+
+// IsAllowed checks if a request is allowed
+func (f *FallbackRateLimiter) IsAllowed(ctx context.Context, key string, limit int, window time.Duration) (*RateLimitResult, error) {
+	return f.decide(ctx,
+		func() (*RateLimitResult, error) { return f.primary.IsAllowed(ctx, key, limit, window) },
+		func() (*RateLimitResult, error) { return f.local.IsAllowed(ctx, key, f.localLimit(limit), window) },
+	)
+}
+
+// IsAllowedN implements CostLimiter when the primary limiter does
+func (f *FallbackRateLimiter) IsAllowedN(ctx context.Context, key string, limit int, window time.Duration, n int) (*RateLimitResult, error) {
+	costLimiter, ok := f.primary.(CostLimiter)
+	if !ok {
+		return f.IsAllowed(ctx, key, limit, window)
+	}
+
+	return f.decide(ctx,
+		func() (*RateLimitResult, error) { return costLimiter.IsAllowedN(ctx, key, limit, window, n) },
+		func() (*RateLimitResult, error) { return f.local.IsAllowedN(ctx, key, f.localLimit(limit), window, n) },
+	)
+}
+
+// Adjust implements CostLimiter, adjusting whichever limiter is deciding
+func (f *FallbackRateLimiter) Adjust(ctx context.Context, key string, window time.Duration, delta int) error {
+	if f.Degraded() {
+		return f.local.Adjust(ctx, key, window, delta)
+	}
+	costLimiter, ok := f.primary.(CostLimiter)
+	if !ok {
+		return ErrAdjustUnsupported
+	}
+	return costLimiter.Adjust(ctx, key, window, delta)
+}
+
+// Peek implements Peeker when the primary limiter does, and returns
+// ErrPeekUnsupported otherwise
+func (f *FallbackRateLimiter) Peek(ctx context.Context, key string, limit int, window time.Duration) (*RateLimitResult, error) {
+	peeker, ok := f.primary.(Peeker)
+	if !ok {
+		return nil, ErrPeekUnsupported
+	}
+
+	return f.decide(ctx,
+		func() (*RateLimitResult, error) { return peeker.Peek(ctx, key, limit, window) },
+		func() (*RateLimitResult, error) { return f.local.Peek(ctx, key, f.localLimit(limit), window) },
+	)
+}
+
+// Reset implements Resetter, clearing the key in both limiters
+func (f *FallbackRateLimiter) Reset(ctx context.Context, key string) error {
+	f.local.Reset(ctx, key)
+	resetter, ok := f.primary.(Resetter)
+	if !ok {
+		return fmt.Errorf("limiter does not support reset")
+	}
+	return resetter.Reset(ctx, key)
+}
+
+// Degraded reports whether decisions currently come from the local limiter
+func (f *FallbackRateLimiter) Degraded() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.stats.Degraded
+}
+
+// Stats returns a snapshot of the fallback counters
+func (f *FallbackRateLimiter) Stats() FallbackStats {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.stats
+}
+
+// decide runs the primary decision unless degraded, switching between the
+// primary and local limiter as the primary fails and recovers
+func (f *FallbackRateLimiter) decide(ctx context.Context, primary, local func() (*RateLimitResult, error)) (*RateLimitResult, error) {
+	f.mu.Lock()
+	skip := f.stats.Degraded && time.Now().Before(f.nextProbe)
+	if skip {
+		f.stats.LocalDecisions++
+	}
+	f.mu.Unlock()
+	if skip {
+		return local()
+	}
+
+	result, err := primary()
+	if err != nil {
+		// A caller that went away says nothing about the primary
+		if errors.Is(err, context.Canceled) && ctx.Err() != nil {
+			return nil, err
+		}
+		// A caller out of its latency budget says the primary is slow, not
+		// down, so only this request goes without it
+		if errors.Is(err, context.DeadlineExceeded) && ctx.Err() != nil {
+			f.mu.Lock()
+			f.stats.Overruns++
+			if !f.config.PassOverruns {
+				f.stats.LocalDecisions++
+			}
+			f.mu.Unlock()
+
+			if f.config.PassOverruns {
+				return nil, err
+			}
+			return local()
+		}
+
+		f.mu.Lock()
+		f.stats.PrimaryErrors++
+		f.stats.LocalDecisions++
+		f.nextProbe = time.Now().Add(f.config.RetryInterval)
+		switched := !f.stats.Degraded
+		if switched {
+			f.switchLocked(true)
+		}
+		f.mu.Unlock()
+
+		if switched && f.config.OnSwitch != nil {
+			f.config.OnSwitch(FallbackEvent{Degraded: true, Err: err, At: time.Now()})
+		}
+
+		// The caller's deadline may have passed, local decisions don't need it
+		return local()
+	}
+
+	f.mu.Lock()
+	f.stats.PrimaryDecisions++
+	switched := f.stats.Degraded
+	if switched {
+		f.switchLocked(false)
+	}
+	f.mu.Unlock()
+
+	if switched && f.config.OnSwitch != nil {
+		f.config.OnSwitch(FallbackEvent{Degraded: false, At: time.Now()})
+	}
+	return result, nil
+}
+
+// switchLocked records a switch. f.mu must be held.
+func (f *FallbackRateLimiter) switchLocked(degraded bool) {
+	f.stats.Degraded = degraded
+	f.stats.Switches++
+	f.stats.LastSwitch = time.Now()
+}
+
+// localLimit returns this instance's share of limit
+func (f *FallbackRateLimiter) localLimit(limit int) int {
+	return max(limit/f.config.Instances, 1)
+}
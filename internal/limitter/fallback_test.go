@@ -0,0 +1,166 @@
+// internal/limitter/fallback_test.go
+package limitter
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// flakyLimiter allows every request until it is given an error
+type flakyLimiter struct {
+	mu    sync.Mutex
+	err   error
+	calls int
+}
+
+func (l *flakyLimiter) IsAllowed(ctx context.Context, key string, limit int, window time.Duration) (*RateLimitResult, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.calls++
+	if l.err != nil {
+		return nil, l.err
+	}
+	return &RateLimitResult{Allowed: true, Remaining: limit, ResetTime: time.Now().Add(window)}, nil
+}
+
+func (l *flakyLimiter) fail(err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.err = err
+}
+
+func TestFallbackLocalDecisions(t *testing.T) {
+	tests := []struct {
+		name      string
+		instances int
+		requests  int
+		allowed   int
+	}{
+		{name: "whole limit", instances: 1, requests: 12, allowed: 10},
+		{name: "share of the limit", instances: 4, requests: 12, allowed: 2},
+		{name: "at least one request", instances: 20, requests: 3, allowed: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			primary := &flakyLimiter{err: errors.New("connection refused")}
+			fallback := NewFallbackRateLimiter(primary, FallbackConfig{Instances: tt.instances})
+			ctx := context.Background()
+
+			allowed := 0
+			for i := 0; i < tt.requests; i++ {
+				result, err := fallback.IsAllowed(ctx, "rate_limit:test", 10, time.Minute)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if result.Allowed {
+					allowed++
+				}
+			}
+
+			if allowed != tt.allowed {
+				t.Errorf("got %d requests allowed locally, want %d", allowed, tt.allowed)
+			}
+			// The primary is not retried before RetryInterval passed
+			if primary.calls != 1 {
+				t.Errorf("got %d primary calls, want 1", primary.calls)
+			}
+		})
+	}
+}
+
+func TestFallbackSwitches(t *testing.T) {
+	primary := &flakyLimiter{}
+	var events []FallbackEvent
+	fallback := NewFallbackRateLimiter(primary, FallbackConfig{
+		RetryInterval: 10 * time.Millisecond,
+		OnSwitch:      func(event FallbackEvent) { events = append(events, event) },
+	})
+	ctx := context.Background()
+
+	decide := func() {
+		t.Helper()
+		if _, err := fallback.IsAllowed(ctx, "rate_limit:test", 10, time.Minute); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	decide()
+	if fallback.Degraded() || len(events) != 0 {
+		t.Fatalf("got degraded %v with %d switches while the primary works", fallback.Degraded(), len(events))
+	}
+
+	primary.fail(errors.New("connection refused"))
+	decide()
+	decide()
+	if !fallback.Degraded() || len(events) != 1 || !events[0].Degraded || events[0].Err == nil {
+		t.Fatalf("got degraded %v with switches %+v after the primary failed", fallback.Degraded(), events)
+	}
+
+	primary.fail(nil)
+	time.Sleep(20 * time.Millisecond)
+	decide()
+	if fallback.Degraded() || len(events) != 2 || events[1].Degraded {
+		t.Fatalf("got degraded %v with switches %+v after the primary recovered", fallback.Degraded(), events)
+	}
+
+	stats := fallback.Stats()
+	if stats.Switches != 2 || stats.PrimaryErrors != 1 || stats.LocalDecisions != 2 || stats.PrimaryDecisions != 2 {
+		t.Errorf("got stats %+v", stats)
+	}
+}
+
+func TestFallbackIgnoresCanceledCallers(t *testing.T) {
+	primary := &flakyLimiter{err: context.Canceled}
+	fallback := NewFallbackRateLimiter(primary, FallbackConfig{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := fallback.IsAllowed(ctx, "rate_limit:test", 10, time.Minute); !errors.Is(err, context.Canceled) {
+		t.Fatalf("got error %v, want context.Canceled", err)
+	}
+	if fallback.Degraded() {
+		t.Error("got degraded after the caller went away")
+	}
+}
+
+func TestFallbackBudgetOverruns(t *testing.T) {
+	tests := []struct {
+		name         string
+		passOverruns bool
+		wantErr      bool
+	}{
+		{name: "decided locally"},
+		{name: "passed to the caller", passOverruns: true, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			primary := &flakyLimiter{err: context.DeadlineExceeded}
+			fallback := NewFallbackRateLimiter(primary, FallbackConfig{PassOverruns: tt.passOverruns})
+
+			ctx, cancel := context.WithTimeout(context.Background(), 0)
+			defer cancel()
+			result, err := fallback.IsAllowed(ctx, "rate_limit:test", 10, time.Minute)
+			if gotErr := errors.Is(err, context.DeadlineExceeded); gotErr != tt.wantErr {
+				t.Fatalf("got error %v, want deadline exceeded %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && (result == nil || !result.Allowed) {
+				t.Errorf("got result %+v, want a local decision", result)
+			}
+
+			// The next caller within its budget goes to the primary again
+			primary.fail(nil)
+			if _, err := fallback.IsAllowed(context.Background(), "rate_limit:test", 10, time.Minute); err != nil {
+				t.Fatal(err)
+			}
+			stats := fallback.Stats()
+			if stats.Degraded || stats.Switches != 0 || stats.Overruns != 1 || stats.PrimaryDecisions != 1 {
+				t.Errorf("got stats %+v", stats)
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+// internal/limitter/gcra_test.go
+package limitter
+
+import (
+	"context"
+	"testing"
+)
+
+// scriptStep is one decision of a limiter script at a fixed time and its
+// expected reply
+type scriptStep struct {
+	// Microseconds after the start of the test
+	at        int64
+	n         int
+	allowed   bool
+	remaining int64
+	retryUs   int64
+}
+
+// testStart is an arbitrary time in microseconds the script tests start at
+const testStart = int64(1_760_000_000_000_000)
+
+// runScript evaluates a limiter script and returns {allowed, remaining,
+// reset_us, retry_us, migrated}
+func runScript(t *testing.T, client RedisClient, script string, keys []string, args ...interface{}) []int64 {
+	t.Helper()
+	val, err := client.Eval(context.Background(), script, keys, args...).Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	reply, ok := val.([]interface{})
+	if !ok || len(reply) != 5 {
+		t.Fatalf("unexpected reply %v", val)
+	}
+	values := make([]int64, len(reply))
+	for i, v := range reply {
+		values[i], _ = v.(int64)
+	}
+	return values
+}
+
+func TestGCRAScript(t *testing.T) {
+	// 10 requests per minute, one every 6s
+	const window, interval = int64(60_000_000), int64(6_000_000)
+
+	tests := []struct {
+		name  string
+		steps []scriptStep
+	}{
+		{
+			name: "burst up to the limit",
+			steps: []scriptStep{
+				{at: 0, n: 10, allowed: true, remaining: 0},
+				{at: 0, n: 1, allowed: false, remaining: 0, retryUs: interval},
+			},
+		},
+		{
+			name: "cost that doesn't fit is denied without a charge",
+			steps: []scriptStep{
+				{at: 0, n: 4, allowed: true, remaining: 6},
+				{at: 0, n: 7, allowed: false, remaining: 6, retryUs: interval},
+				{at: 0, n: 6, allowed: true, remaining: 0},
+			},
+		},
+		{
+			name: "cost above the limit is denied and doesn't block smaller ones",
+			steps: []scriptStep{
+				{at: 0, n: 11, allowed: false, remaining: 10, retryUs: interval},
+				{at: 0, n: 10, allowed: true, remaining: 0},
+			},
+		},
+		{
+			name: "one request per interval after a burst",
+			steps: []scriptStep{
+				{at: 0, n: 10, allowed: true, remaining: 0},
+				{at: interval / 2, n: 1, allowed: false, remaining: 0, retryUs: interval / 2},
+				{at: interval, n: 1, allowed: true, remaining: 0},
+			},
+		},
+		{
+			name: "idle keys recover the full burst",
+			steps: []scriptStep{
+				{at: 0, n: 10, allowed: true, remaining: 0},
+				{at: 2 * window, n: 1, allowed: true, remaining: 9},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, client := newTestRedis(t)
+			for i, step := range tt.steps {
+				now := testStart + step.at
+				got := runScript(t, client, gcraScript, []string{"rate_limit:test:gcra:v1"}, now, interval, window, step.n, "0")
+				if (got[0] == 1) != step.allowed || got[1] != step.remaining || got[3] != step.retryUs || got[4] != 0 {
+					t.Fatalf("step %d: got %v, want allowed=%v remaining=%d retry=%d", i, got, step.allowed, step.remaining, step.retryUs)
+				}
+			}
+
+			// State is a hash of the TAT and the emission interval
+			if got := server.HGet("rate_limit:test:gcra:v1", "interval"); got != "6000000" {
+				t.Errorf("got interval %q, want 6000000", got)
+			}
+			if server.HGet("rate_limit:test:gcra:v1", "tat") == "" {
+				t.Error("got no TAT")
+			}
+		})
+	}
+}
+
+func TestGCRAAdjustScript(t *testing.T) {
+	const window, interval = int64(60_000_000), int64(6_000_000)
+
+	tests := []struct {
+		name      string
+		charged   int
+		delta     int
+		remaining int64
+	}{
+		{name: "refund", charged: 6, delta: -4, remaining: 8},
+		{name: "refund more than charged", charged: 2, delta: -5, remaining: 10},
+		{name: "extra charge", charged: 2, delta: 3, remaining: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, client := newTestRedis(t)
+			keys := []string{"rate_limit:test:gcra:v1"}
+			runScript(t, client, gcraScript, keys, testStart, interval, window, tt.charged, "0")
+
+			if err := client.Eval(context.Background(), gcraAdjustScript, keys, testStart, tt.delta).Err(); err != nil {
+				t.Fatal(err)
+			}
+
+			got := runScript(t, client, gcraScript, keys, testStart, interval, window, 0, "0")
+			if got[1] != tt.remaining {
+				t.Errorf("got remaining %d, want %d", got[1], tt.remaining)
+			}
+		})
+	}
+}
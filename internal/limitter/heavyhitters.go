@@ -0,0 +1,258 @@
+// internal/limitter/heavyhitters.go
+package limitter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// HeavyHitterConfig holds configuration for top-N key tracking
+type HeavyHitterConfig struct {
+	// Length of one reporting interval
+	Interval time.Duration
+	// Number of intervals kept in Redis
+	Retention int
+	// Number of keys kept per interval, lighter keys are trimmed
+	Capacity int
+	// Share of a policy's traffic above which a key is reported
+	AlertShare float64
+	// Number of consecutive completed intervals a key must stay above AlertShare
+	AlertIntervals int
+	// Called for every key that stays above AlertShare
+	OnAlert func(HeavyHitterAlert)
+	// Key prefix for Redis keys
+	KeyPrefix string
+}
+
+// KeyUsage describes how much of a policy's traffic one key consumed
+type KeyUsage struct {
+	Key   string  `json:"key"`
+	Count int64   `json:"count"`
+	Share float64 `json:"share"`
+}
+
+// HeavyHitterAlert reports a key that stayed above the configured share of traffic
+type HeavyHitterAlert struct {
+	Policy    string
+	Key       string
+	Share     float64
+	Intervals int
+}
+
+// HeavyHitterTracker keeps an approximate top-N of keys per policy using one
+// sorted set per interval
+type HeavyHitterTracker struct {
+	client  RedisClient
+	config  HeavyHitterConfig
+	records atomic.Int64
+}
+
+// NewHeavyHitterTracker creates a new Redis-based heavy-hitter tracker
+func NewHeavyHitterTracker(client RedisClient, config HeavyHitterConfig) *HeavyHitterTracker {
+	if config.Interval <= 0 {
+		config.Interval = time.Minute
+	}
+	if config.Retention <= 0 {
+		config.Retention = 60
+	}
+	if config.Capacity <= 0 {
+		config.Capacity = 1000
+	}
+	if config.AlertIntervals <= 0 {
+		config.AlertIntervals = 3
+	}
+	if config.KeyPrefix == "" {
+		config.KeyPrefix = "rate_limit:top:"
+	}
+
+	return &HeavyHitterTracker{
+		client: client,
+		config: config,
+	}
+}
+
+// Record adds cost to the usage of key under policy in the current interval
+func (t *HeavyHitterTracker) Record(ctx context.Context, policy, key string, cost int64) error {
+	index := t.intervalIndex(time.Now())
+	setKey, totalKey := t.intervalKeys(policy, index)
+	ttl := time.Duration(t.config.Retention+1) * t.config.Interval
+
+	pipe := t.client.Pipeline()
+	pipe.ZIncrBy(ctx, setKey, float64(cost), key)
+	pipe.IncrBy(ctx, totalKey, cost)
+	pipe.Expire(ctx, setKey, ttl)
+	pipe.Expire(ctx, totalKey, ttl)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("redis pipeline error: %w", err)
+	}
+
+	// Trim the light keys now and then to bound memory
+	if t.records.Add(1)%1000 == 0 {
+		t.client.ZRemRangeByRank(ctx, setKey, 0, int64(-t.config.Capacity-1))
+	}
+	return nil
+}
+
+// Top returns the n heaviest keys of policy summed over the most recent intervals,
+// including the current one
+func (t *HeavyHitterTracker) Top(ctx context.Context, policy string, n, intervals int) ([]KeyUsage, error) {
+	if intervals <= 0 {
+		intervals = 1
+	}
+	if intervals > t.config.Retention {
+		intervals = t.config.Retention
+	}
+
+	current := t.intervalIndex(time.Now())
+	counts := make(map[string]int64)
+	var total int64
+	for i := 0; i < intervals; i++ {
+		usage, intervalTotal, err := t.intervalTop(ctx, policy, current-int64(i), n)
+		if err != nil {
+			return nil, err
+		}
+		for _, u := range usage {
+			counts[u.Key] += u.Count
+		}
+		total += intervalTotal
+	}
+
+	return rankUsage(counts, total, n), nil
+}
+
+// CheckAlerts reports keys that stayed above AlertShare of the policy's
+// traffic in each of the last AlertIntervals completed intervals
+func (t *HeavyHitterTracker) CheckAlerts(ctx context.Context, policy string) ([]HeavyHitterAlert, error) {
+	if t.config.AlertShare <= 0 {
+		return nil, nil
+	}
+
+	// At most 1/AlertShare keys can be above the share in one interval
+	candidates := int(1/t.config.AlertShare) + 1
+	current := t.intervalIndex(time.Now())
+
+	var minShare map[string]float64
+	for i := 1; i <= t.config.AlertIntervals; i++ {
+		usage, total, err := t.intervalTop(ctx, policy, current-int64(i), candidates)
+		if err != nil {
+			return nil, err
+		}
+
+		shares := make(map[string]float64)
+		for _, u := range usage {
+			if total == 0 {
+				continue
+			}
+			share := float64(u.Count) / float64(total)
+			if share < t.config.AlertShare {
+				continue
+			}
+			if minShare == nil {
+				shares[u.Key] = share
+			} else if prev, ok := minShare[u.Key]; ok {
+				shares[u.Key] = min(prev, share)
+			}
+		}
+		minShare = shares
+	}
+
+	var alerts []HeavyHitterAlert
+	for key, share := range minShare {
+		alert := HeavyHitterAlert{
+			Policy:    policy,
+			Key:       key,
+			Share:     share,
+			Intervals: t.config.AlertIntervals,
+		}
+		alerts = append(alerts, alert)
+		if t.config.OnAlert != nil {
+			t.config.OnAlert(alert)
+		}
+	}
+	return alerts, nil
+}
+
+// Watch runs CheckAlerts for the given policies once per interval until ctx is done
+func (t *HeavyHitterTracker) Watch(ctx context.Context, policies ...string) {
+	ticker := time.NewTicker(t.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, policy := range policies {
+				t.CheckAlerts(ctx, policy)
+			}
+		}
+	}
+}
+
+// intervalTop returns the n heaviest keys and the total usage of one interval
+func (t *HeavyHitterTracker) intervalTop(ctx context.Context, policy string, index int64, n int) ([]KeyUsage, int64, error) {
+	setKey, totalKey := t.intervalKeys(policy, index)
+
+	values, err := t.client.ZRevRange(ctx, setKey, 0, int64(n-1), "WITHSCORES").Result()
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get top keys: %w", err)
+	}
+
+	usage := make([]KeyUsage, 0, len(values)/2)
+	for i := 0; i+1 < len(values); i += 2 {
+		score, err := strconv.ParseFloat(values[i+1], 64)
+		if err != nil {
+			return nil, 0, fmt.Errorf("invalid score %q: %w", values[i+1], err)
+		}
+		usage = append(usage, KeyUsage{Key: values[i], Count: int64(score)})
+	}
+
+	var total int64
+	val, err := t.client.Get(ctx, totalKey).Result()
+	if err != nil && !errors.Is(err, Nil) {
+		return nil, 0, fmt.Errorf("failed to get total usage: %w", err)
+	}
+	if val != "" {
+		total, _ = strconv.ParseInt(val, 10, 64)
+	}
+
+	return usage, total, nil
+}
+
+func (t *HeavyHitterTracker) intervalIndex(now time.Time) int64 {
+	return now.UnixNano() / int64(t.config.Interval)
+}
+
+func (t *HeavyHitterTracker) intervalKeys(policy string, index int64) (string, string) {
+	base := fmt.Sprintf("%s%s:%d", t.config.KeyPrefix, policy, index)
+	return base, base + ":total"
+}
+
+// rankUsage sorts keys by count and returns the n heaviest with their share of total
+func rankUsage(counts map[string]int64, total int64, n int) []KeyUsage {
+	usage := make([]KeyUsage, 0, len(counts))
+	for key, count := range counts {
+		u := KeyUsage{Key: key, Count: count}
+		if total > 0 {
+			u.Share = float64(count) / float64(total)
+		}
+		usage = append(usage, u)
+	}
+
+	sort.Slice(usage, func(i, j int) bool {
+		if usage[i].Count == usage[j].Count {
+			return usage[i].Key < usage[j].Key
+		}
+		return usage[i].Count > usage[j].Count
+	})
+
+	if n > 0 && len(usage) > n {
+		usage = usage[:n]
+	}
+	return usage
+}
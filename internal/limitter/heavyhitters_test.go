@@ -0,0 +1,162 @@
+// internal/limitter/heavyhitters_test.go
+package limitter
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// recordInterval adds usage to an interval the given number of intervals ago
+func recordInterval(t *testing.T, tracker *HeavyHitterTracker, policy string, ago int, usage map[string]int64) {
+	t.Helper()
+	ctx := context.Background()
+	setKey, totalKey := tracker.intervalKeys(policy, tracker.intervalIndex(time.Now())-int64(ago))
+	for key, count := range usage {
+		if err := tracker.client.ZIncrBy(ctx, setKey, float64(count), key).Err(); err != nil {
+			t.Fatal(err)
+		}
+		if err := tracker.client.IncrBy(ctx, totalKey, count).Err(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestHeavyHitterTop(t *testing.T) {
+	tests := []struct {
+		name      string
+		intervals []map[string]int64
+		n         int
+		over      int
+		want      []KeyUsage
+	}{
+		{
+			name:      "ranked by count",
+			intervals: []map[string]int64{{"a": 1, "b": 6, "c": 3}},
+			n:         3,
+			over:      1,
+			want:      []KeyUsage{{"b", 6, 0.6}, {"c", 3, 0.3}, {"a", 1, 0.1}},
+		},
+		{
+			name:      "limited to n keys",
+			intervals: []map[string]int64{{"a": 1, "b": 6, "c": 3}},
+			n:         1,
+			over:      1,
+			want:      []KeyUsage{{"b", 6, 0.6}},
+		},
+		{
+			name:      "summed over intervals",
+			intervals: []map[string]int64{{"a": 2}, {"a": 2, "b": 6}},
+			n:         2,
+			over:      2,
+			want:      []KeyUsage{{"b", 6, 0.6}, {"a", 4, 0.4}},
+		},
+		{
+			name:      "older intervals are left out",
+			intervals: []map[string]int64{{"a": 2}, {"a": 2, "b": 6}},
+			n:         2,
+			over:      1,
+			want:      []KeyUsage{{"a", 2, 1}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, client := newTestRedis(t)
+			tracker := NewHeavyHitterTracker(client, HeavyHitterConfig{Interval: time.Hour})
+			for ago, usage := range tt.intervals {
+				recordInterval(t, tracker, "api", ago, usage)
+			}
+
+			got, err := tracker.Top(context.Background(), "api", tt.n, tt.over)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHeavyHitterRecord(t *testing.T) {
+	_, client := newTestRedis(t)
+	tracker := NewHeavyHitterTracker(client, HeavyHitterConfig{Interval: time.Hour})
+	ctx := context.Background()
+
+	for _, key := range []string{"a", "b", "a"} {
+		if err := tracker.Record(ctx, "api", key, 2); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Other policies are tracked separately
+	if err := tracker.Record(ctx, "login", "b", 10); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := tracker.Top(ctx, "api", 10, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []KeyUsage{{"a", 4, 4.0 / 6}, {"b", 2, 2.0 / 6}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestHeavyHitterAlerts(t *testing.T) {
+	tests := []struct {
+		name string
+		// intervals maps how many intervals ago to the usage in it
+		intervals map[int]map[string]int64
+		want      []string
+	}{
+		{
+			name:      "above the share in every interval",
+			intervals: map[int]map[string]int64{1: {"a": 9, "b": 1}, 2: {"a": 6, "b": 4}},
+			want:      []string{"a"},
+		},
+		{
+			name:      "below the share once",
+			intervals: map[int]map[string]int64{1: {"a": 9, "b": 1}, 2: {"a": 4, "b": 6}},
+			want:      []string{},
+		},
+		{
+			name:      "the current interval doesn't count",
+			intervals: map[int]map[string]int64{0: {"a": 10}, 1: {"b": 9, "a": 1}, 2: {"b": 9, "a": 1}},
+			want:      []string{"b"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, client := newTestRedis(t)
+			var alerted []string
+			tracker := NewHeavyHitterTracker(client, HeavyHitterConfig{
+				Interval:       time.Hour,
+				AlertShare:     0.5,
+				AlertIntervals: 2,
+				OnAlert:        func(alert HeavyHitterAlert) { alerted = append(alerted, alert.Key) },
+			})
+			for ago, usage := range tt.intervals {
+				recordInterval(t, tracker, "api", ago, usage)
+			}
+
+			alerts, err := tracker.CheckAlerts(context.Background(), "api")
+			if err != nil {
+				t.Fatal(err)
+			}
+			keys := []string{}
+			for _, alert := range alerts {
+				keys = append(keys, alert.Key)
+				if alert.Policy != "api" || alert.Share < 0.5 || alert.Intervals != 2 {
+					t.Errorf("got alert %+v", alert)
+				}
+			}
+			if !reflect.DeepEqual(keys, tt.want) || len(alerted) != len(tt.want) {
+				t.Errorf("got alerts %v (callback %v), want %v", keys, alerted, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,267 @@
+// internal/limitter/hybrid.go
+package limitter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// leaseScript grants up to ARGV[2] units of a window's limit ARGV[1] to the
+// caller. Returns {granted, used}.
+const leaseScript = `
+local used = tonumber(redis.call('GET', KEYS[1]) or '0')
+local limit = tonumber(ARGV[1])
+local grant = math.min(tonumber(ARGV[2]), limit - used)
+if grant <= 0 then
+	return {0, used}
+end
+used = redis.call('INCRBY', KEYS[1], grant)
+redis.call('PEXPIRE', KEYS[1], ARGV[3])
+return {grant, used}
+`
+
+// HybridConfig holds configuration for the two-tier limiter
+type HybridConfig struct {
+	// Largest fraction of a limit that may sit unused in local leases across
+	// all instances, which bounds the accuracy error (default 0.05)
+	MaxError float64
+	// Expected number of instances sharing the limit (default 1)
+	Instances int
+	// How long a lease may be served locally before it is dropped (default 1s)
+	LeaseTTL time.Duration
+}
+
+// HybridRateLimiter leases batches of quota from Redis into a per-instance
+// allowance and serves decisions locally until the lease runs out or expires.
+// Windows are fixed. Unused quota goes back to Redis when its lease expires
+// within the window or is released; until then the limiter errs on the side
+// of rejecting by at most MaxError of the limit.
+type HybridRateLimiter struct {
+	client RedisClient
+	config HybridConfig
+
+	mu        sync.Mutex
+	leases    map[string]*quotaLease
+	lastPrune time.Time
+}
+
+// quotaLease is the local allowance leased for one key and window
+type quotaLease struct {
+	mu        sync.Mutex
+	windowKey string
+	tokens    int
+	remaining int
+	expires   time.Time
+}
+
+// NewHybridRateLimiter creates a new two-tier rate limiter
+func NewHybridRateLimiter(client RedisClient, config HybridConfig) *HybridRateLimiter {
+	if config.MaxError <= 0 || config.MaxError >= 1 {
+		config.MaxError = 0.05
+	}
+	if config.Instances <= 0 {
+		config.Instances = 1
+	}
+	if config.LeaseTTL <= 0 {
+		config.LeaseTTL = time.Second
+	}
+
+	return &HybridRateLimiter{
+		client:    client,
+		config:    config,
+		leases:    make(map[string]*quotaLease),
+		lastPrune: time.Now(),
+	}
+}
+
+// IsAllowed serves the request from the local lease, leasing more quota from
+// Redis when the lease is empty or stale
+func (h *HybridRateLimiter) IsAllowed(ctx context.Context, key string, limit int, window time.Duration) (*RateLimitResult, error) {
+	now := time.Now()
+	index := now.UnixNano() / int64(window)
+	windowKey := fmt.Sprintf("%s:lease:%d", key, index)
+	resetTime := time.Unix(0, (index+1)*int64(window))
+
+	lease := h.lease(key)
+	lease.mu.Lock()
+	defer lease.mu.Unlock()
+
+	if lease.windowKey != windowKey || now.After(lease.expires) || lease.tokens <= 0 {
+		// Quota left in an expired lease of this window goes back first
+		if lease.windowKey == windowKey && lease.tokens > 0 {
+			if err := h.giveBack(ctx, windowKey, lease.tokens); err != nil {
+				return nil, err
+			}
+			lease.tokens = 0
+		}
+
+		granted, used, err := h.acquire(ctx, windowKey, limit, resetTime)
+		if err != nil {
+			return nil, err
+		}
+
+		lease.windowKey = windowKey
+		lease.tokens = granted
+		lease.remaining = limit - used
+		lease.expires = now.Add(h.config.LeaseTTL)
+	}
+
+	if lease.tokens <= 0 {
+		return &RateLimitResult{
+			Allowed:    false,
+			Remaining:  0,
+			ResetTime:  resetTime,
+			RetryAfter: time.Until(resetTime),
+		}, nil
+	}
+
+	lease.tokens--
+	return &RateLimitResult{
+		Allowed:     true,
+		Remaining:   lease.remaining + lease.tokens,
+		ResetTime:   resetTime,
+		Reservation: h.reservation(lease, windowKey),
+	}, nil
+}
+
+// Reset drops the local lease of key and clears its windows in Redis.
+// Leases already held by other instances are served until they expire.
+func (h *HybridRateLimiter) Reset(ctx context.Context, key string) error {
+	h.mu.Lock()
+	lease, ok := h.leases[key]
+	delete(h.leases, key)
+	h.mu.Unlock()
+	if ok {
+		lease.mu.Lock()
+		lease.tokens = 0
+		lease.mu.Unlock()
+	}
+
+	// Other instances may have leased from windows this one never saw
+	var cursor uint64
+	for {
+		keys, next, err := h.client.Scan(ctx, cursor, key+":lease:*", 100).Result()
+		if err != nil {
+			return fmt.Errorf("failed to scan leases: %w", err)
+		}
+		if len(keys) > 0 {
+			if err := h.client.Del(ctx, keys...).Err(); err != nil {
+				return fmt.Errorf("failed to reset key: %w", err)
+			}
+		}
+
+		cursor = next
+		if cursor == 0 {
+			return nil
+		}
+	}
+}
+
+// Release returns the unused quota of every lease to Redis and drops the
+// leases, e.g. before the instance shuts down
+func (h *HybridRateLimiter) Release(ctx context.Context) error {
+	h.mu.Lock()
+	leases := h.leases
+	h.leases = make(map[string]*quotaLease)
+	h.mu.Unlock()
+
+	var errs []error
+	for _, lease := range leases {
+		lease.mu.Lock()
+		if lease.tokens > 0 {
+			if err := h.giveBack(ctx, lease.windowKey, lease.tokens); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		lease.tokens = 0
+		lease.mu.Unlock()
+	}
+	return errors.Join(errs...)
+}
+
+// leaseSize returns how many units one instance may lease at a time
+func (h *HybridRateLimiter) leaseSize(limit int) int {
+	size := int(h.config.MaxError * float64(limit) / float64(h.config.Instances))
+	if size < 1 {
+		size = 1
+	}
+	return size
+}
+
+// acquire leases quota for the window from Redis
+func (h *HybridRateLimiter) acquire(ctx context.Context, windowKey string, limit int, resetTime time.Time) (int, int, error) {
+	ttl := time.Until(resetTime) + time.Minute
+	val, err := h.client.Eval(ctx, leaseScript, []string{windowKey}, limit, h.leaseSize(limit), ttl.Milliseconds()).Result()
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to lease quota: %w", err)
+	}
+
+	reply, ok := val.([]interface{})
+	if !ok || len(reply) != 2 {
+		return 0, 0, fmt.Errorf("unexpected lease reply: %v", val)
+	}
+	granted, _ := reply[0].(int64)
+	used, _ := reply[1].(int64)
+	return int(granted), int(used), nil
+}
+
+// giveBack returns n unused units of a lease to the window in Redis
+func (h *HybridRateLimiter) giveBack(ctx context.Context, windowKey string, n int) error {
+	if err := h.client.IncrBy(ctx, windowKey, -int64(n)).Err(); err != nil {
+		return fmt.Errorf("failed to return leased quota: %w", err)
+	}
+	return nil
+}
+
+// reservation returns a unit to the lease it came from, or to Redis once
+// that lease has been replaced
+func (h *HybridRateLimiter) reservation(lease *quotaLease, windowKey string) Reservation {
+	return newReservation(func(ctx context.Context) error {
+		lease.mu.Lock()
+		defer lease.mu.Unlock()
+
+		if lease.windowKey == windowKey && time.Now().Before(lease.expires) {
+			lease.tokens++
+			return nil
+		}
+		return h.giveBack(ctx, windowKey, 1)
+	})
+}
+
+// lease returns the local lease for key, dropping stale leases now and then
+func (h *HybridRateLimiter) lease(key string) *quotaLease {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(h.lastPrune) > time.Minute {
+		for k, l := range h.leases {
+			if l.mu.TryLock() {
+				if now.After(l.expires) {
+					delete(h.leases, k)
+					// The window may still be running, so its quota goes back
+					if l.tokens > 0 {
+						go func(windowKey string, n int) {
+							ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+							defer cancel()
+							h.giveBack(ctx, windowKey, n)
+						}(l.windowKey, l.tokens)
+						l.tokens = 0
+					}
+				}
+				l.mu.Unlock()
+			}
+		}
+		h.lastPrune = now
+	}
+
+	l, ok := h.leases[key]
+	if !ok {
+		l = &quotaLease{}
+		h.leases[key] = l
+	}
+	return l
+}
@@ -0,0 +1,95 @@
+// internal/limitter/hybrid_test.go
+package limitter
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// leasedUnits returns the units of key's current window leased from Redis
+func leasedUnits(t *testing.T, server *miniredis.Miniredis, key string, window time.Duration) int {
+	t.Helper()
+	index := time.Now().UnixNano() / int64(window)
+	val, err := server.Get(key + ":lease:" + strconv.FormatInt(index, 10))
+	if err != nil {
+		return 0
+	}
+	n, _ := strconv.Atoi(val)
+	return n
+}
+
+func TestHybridGivesBackUnusedQuota(t *testing.T) {
+	tests := []struct {
+		name     string
+		requests int
+		release  func(context.Context, *HybridRateLimiter) error
+		leased   int
+	}{
+		{
+			name:     "lease held",
+			requests: 2,
+			release:  func(ctx context.Context, h *HybridRateLimiter) error { return nil },
+			leased:   10,
+		},
+		{
+			name:     "released on shutdown",
+			requests: 2,
+			release:  func(ctx context.Context, h *HybridRateLimiter) error { return h.Release(ctx) },
+			leased:   2,
+		},
+		{
+			name:     "expired lease is replaced",
+			requests: 2,
+			release: func(ctx context.Context, h *HybridRateLimiter) error {
+				time.Sleep(20 * time.Millisecond)
+				_, err := h.IsAllowed(ctx, "rate_limit:test", 100, time.Hour)
+				return err
+			},
+			// Two units used and one more from the new lease of ten
+			leased: 12,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, client := newTestRedis(t)
+			hybrid := NewHybridRateLimiter(client, HybridConfig{MaxError: 0.1, LeaseTTL: 10 * time.Millisecond})
+			ctx := context.Background()
+
+			for i := 0; i < tt.requests; i++ {
+				if _, err := hybrid.IsAllowed(ctx, "rate_limit:test", 100, time.Hour); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := tt.release(ctx, hybrid); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := leasedUnits(t, server, "rate_limit:test", time.Hour); got != tt.leased {
+				t.Errorf("got %d units leased, want %d", got, tt.leased)
+			}
+		})
+	}
+}
+
+func TestHybridResetWithoutLocalLease(t *testing.T) {
+	server, client := newTestRedis(t)
+	ctx := context.Background()
+
+	// Another instance leased quota for the key
+	other := NewHybridRateLimiter(client, HybridConfig{MaxError: 0.1})
+	if _, err := other.IsAllowed(ctx, "rate_limit:test", 100, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := NewHybridRateLimiter(client, HybridConfig{}).Reset(ctx, "rate_limit:test"); err != nil {
+		t.Fatal(err)
+	}
+	if got := leasedUnits(t, server, "rate_limit:test", time.Hour); got != 0 {
+		t.Errorf("got %d units leased after reset, want 0", got)
+	}
+}
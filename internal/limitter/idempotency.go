@@ -0,0 +1,106 @@
+// internal/limitter/idempotency.go
+package limitter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrIdempotencyMismatch is returned when an idempotency key is reused for a
+// different request
+var ErrIdempotencyMismatch = errors.New("limitter: idempotency key reused with a different request")
+
+// IdempotencyState is what a tracker knows about an idempotency key
+type IdempotencyState int
+
+const (
+	// IdempotencyNew means the key was not seen before and is now recorded
+	IdempotencyNew IdempotencyState = iota
+	// IdempotencyInFlight means the first request with the key is still running
+	IdempotencyInFlight
+	// IdempotencyDone means the first request with the key was served
+	IdempotencyDone
+)
+
+// idempotencyPending and idempotencyDone mark the state stored after the fingerprint
+const (
+	idempotencyPending = "pending"
+	idempotencyDone    = "done"
+)
+
+// IdempotencyTracker remembers idempotency keys per client so that safe
+// retries are not charged twice. Each key is bound to the fingerprint of the
+// request that first used it.
+type IdempotencyTracker struct {
+	client    RedisClient
+	ttl       time.Duration
+	keyPrefix string
+}
+
+// NewIdempotencyTracker creates a tracker that remembers keys for ttl
+func NewIdempotencyTracker(client RedisClient, ttl time.Duration) *IdempotencyTracker {
+	if ttl <= 0 {
+		ttl = 24 * time.Hour
+	}
+
+	return &IdempotencyTracker{
+		client:    client,
+		ttl:       ttl,
+		keyPrefix: "rate_limit:idempotency:",
+	}
+}
+
+// Remember records idempotencyKey for clientKey as in flight and reports the
+// state it already had. It returns ErrIdempotencyMismatch when the key was
+// first used by a request with another fingerprint.
+func (t *IdempotencyTracker) Remember(ctx context.Context, clientKey, idempotencyKey, fingerprint string) (IdempotencyState, error) {
+	key := t.key(clientKey, idempotencyKey)
+	created, err := t.client.SetNX(ctx, key, fingerprint+":"+idempotencyPending, t.ttl).Result()
+	if err != nil {
+		return IdempotencyNew, fmt.Errorf("failed to record idempotency key: %w", err)
+	}
+	if created {
+		return IdempotencyNew, nil
+	}
+
+	val, err := t.client.Get(ctx, key).Result()
+	if errors.Is(err, Nil) {
+		// Expired in between, the first request is no longer known
+		return IdempotencyInFlight, nil
+	}
+	if err != nil {
+		return IdempotencyNew, fmt.Errorf("failed to read idempotency key: %w", err)
+	}
+
+	seen, state, _ := strings.Cut(val, ":")
+	if seen != fingerprint {
+		return IdempotencyNew, ErrIdempotencyMismatch
+	}
+	if state == idempotencyDone {
+		return IdempotencyDone, nil
+	}
+	return IdempotencyInFlight, nil
+}
+
+// Complete marks the request that recorded idempotencyKey as served
+func (t *IdempotencyTracker) Complete(ctx context.Context, clientKey, idempotencyKey, fingerprint string) error {
+	if err := t.client.Set(ctx, t.key(clientKey, idempotencyKey), fingerprint+":"+idempotencyDone, t.ttl).Err(); err != nil {
+		return fmt.Errorf("failed to complete idempotency key: %w", err)
+	}
+	return nil
+}
+
+// Forget removes idempotencyKey so that the next request with it is charged
+func (t *IdempotencyTracker) Forget(ctx context.Context, clientKey, idempotencyKey string) error {
+	if err := t.client.Del(ctx, t.key(clientKey, idempotencyKey)).Err(); err != nil {
+		return fmt.Errorf("failed to forget idempotency key: %w", err)
+	}
+	return nil
+}
+
+func (t *IdempotencyTracker) key(clientKey, idempotencyKey string) string {
+	return t.keyPrefix + clientKey + ":" + idempotencyKey
+}
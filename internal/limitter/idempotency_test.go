@@ -0,0 +1,69 @@
+// internal/limitter/idempotency_test.go
+package limitter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestIdempotencyTrackerRemember(t *testing.T) {
+	tests := []struct {
+		name        string
+		fingerprint string
+		complete    bool
+		state       IdempotencyState
+		err         error
+	}{
+		{name: "retry while the first request runs", fingerprint: "a", state: IdempotencyInFlight},
+		{name: "retry after the first request", fingerprint: "a", complete: true, state: IdempotencyDone},
+		{name: "key reused for another request", fingerprint: "b", err: ErrIdempotencyMismatch},
+		{name: "key reused after the first request", fingerprint: "b", complete: true, err: ErrIdempotencyMismatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, client := newTestRedis(t)
+			tracker := NewIdempotencyTracker(client, time.Hour)
+			ctx := context.Background()
+
+			state, err := tracker.Remember(ctx, "ip:1.2.3.4", "key-1", "a")
+			if err != nil || state != IdempotencyNew {
+				t.Fatalf("first request: got %v, %v, want IdempotencyNew", state, err)
+			}
+			if tt.complete {
+				if err := tracker.Complete(ctx, "ip:1.2.3.4", "key-1", "a"); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			state, err = tracker.Remember(ctx, "ip:1.2.3.4", "key-1", tt.fingerprint)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if err == nil && state != tt.state {
+				t.Errorf("got state %v, want %v", state, tt.state)
+			}
+		})
+	}
+}
+
+func TestIdempotencyTrackerForget(t *testing.T) {
+	_, client := newTestRedis(t)
+	tracker := NewIdempotencyTracker(client, time.Hour)
+	ctx := context.Background()
+
+	if _, err := tracker.Remember(ctx, "ip:1.2.3.4", "key-1", "a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tracker.Forget(ctx, "ip:1.2.3.4", "key-1"); err != nil {
+		t.Fatal(err)
+	}
+
+	// A forgotten key may be used again, even by another request
+	state, err := tracker.Remember(ctx, "ip:1.2.3.4", "key-1", "b")
+	if err != nil || state != IdempotencyNew {
+		t.Fatalf("got %v, %v, want IdempotencyNew", state, err)
+	}
+}
@@ -3,16 +3,39 @@ package limitter
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 )
 
+// Nil is returned by Redis commands when the requested key does not exist
+var Nil = errors.New("limitter: nil")
+
+// ErrInvalidCost is returned for requests with a negative cost
+var ErrInvalidCost = errors.New("limitter: cost must not be negative")
+
+// ErrPeekUnsupported is returned by Peek of wrappers whose wrapped limiter
+// can't peek
+var ErrPeekUnsupported = errors.New("limitter: limiter cannot peek")
+
+// ErrAdjustUnsupported is returned by Adjust of wrappers whose wrapped
+// limiter can't adjust a charge
+var ErrAdjustUnsupported = errors.New("limitter: limiter cannot adjust charges")
+
 // RateLimitResult represents the result of a rate limit check
 type RateLimitResult struct {
 	Allowed    bool
 	Remaining  int
 	ResetTime  time.Time
 	RetryAfter time.Duration
+	// Reservation undoes the charge of this decision when refunded (nil for Peek)
+	Reservation Reservation
+	// Early marks rejections made before the limit was reached, which are
+	// not limit violations
+	Early bool
 }
 
 // RateLimiter defines the interface for rate limiting
@@ -20,6 +43,24 @@ type RateLimiter interface {
 	IsAllowed(ctx context.Context, key string, limit int, window time.Duration) (*RateLimitResult, error)
 }
 
+// Peeker is implemented by rate limiters that can inspect a key without
+// recording a request
+type Peeker interface {
+	Peek(ctx context.Context, key string, limit int, window time.Duration) (*RateLimitResult, error)
+}
+
+// CostLimiter is implemented by rate limiters that support weighted requests
+// and adjusting a charge after the fact
+type CostLimiter interface {
+	IsAllowedN(ctx context.Context, key string, limit int, window time.Duration, n int) (*RateLimitResult, error)
+	Adjust(ctx context.Context, key string, window time.Duration, delta int) error
+}
+
+// Resetter is implemented by rate limiters that can clear the usage of a key
+type Resetter interface {
+	Reset(ctx context.Context, key string) error
+}
+
 // Config holds rate limiter configuration
 type Config struct {
 	DefaultLimit  int
@@ -42,20 +83,23 @@ func NewRedisRateLimiter(client RedisClient, config *Config) *RedisRateLimiter {
 
 // IsAllowed checks if a request is allowed based on rate limits
 func (r *RedisRateLimiter) IsAllowed(ctx context.Context, key string, limit int, window time.Duration) (*RateLimitResult, error) {
-	now := time.Now()
-	windowStart := now.Add(-window)
+	return r.IsAllowedN(ctx, key, limit, window, 1)
+}
+
+// IsAllowedN checks if a request costing n units is allowed based on rate
+// limits. n must not be negative. A request costing more than limit can never
+// be allowed and is rejected without being logged.
+func (r *RedisRateLimiter) IsAllowedN(ctx context.Context, key string, limit int, window time.Duration, n int) (*RateLimitResult, error) {
+	if n < 0 {
+		return nil, ErrInvalidCost
+	}
+	if n > limit {
+		return rejectOversized(r.Peek(ctx, key, limit, window))
+	}
 	
 	// Use sliding window log approach
 	pipe := r.client.Pipeline()
-	
-	// Remove old entries
-	pipe.ZRemRangeByScore(ctx, key, "0", fmt.Sprintf("%.0f", float64(windowStart.UnixNano())))
-	
-	// Add current request
-	pipe.ZAdd(ctx, key, float64(now.UnixNano()), fmt.Sprintf("%.0f", float64(now.UnixNano())))
-	
-	// Count current requests in window
-	countCmd := pipe.ZCard(ctx, key)
+	entry := r.queueLog(ctx, pipe, key, window, n, time.Now())
 	
 	// Set expiration for cleanup
 	r.client.Expire(ctx, key, window+time.Minute)
@@ -66,11 +110,88 @@ func (r *RedisRateLimiter) IsAllowed(ctx context.Context, key string, limit int,
 		return nil, fmt.Errorf("redis pipeline error: %w", err)
 	}
 	
-	// Get count result
-	count, err := countCmd.Result()
+	return r.logResult(entry, limit)
+}
+
+// slidingLogScript trims the log at KEYS[1] to the window starting at
+// ARGV[1] and logs the request costing ARGV[4] as the single member ARGV[3]
+// scored ARGV[2]. Like the individual commands it replaces, it logs every
+// attempt. Returns the summed cost of the entries in the window.
+const slidingLogScript = `
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '0', ARGV[1])
+if tonumber(ARGV[4]) > 0 then
+	redis.call('ZADD', KEYS[1], ARGV[2], ARGV[3])
+end
+local used = 0
+for _, member in ipairs(redis.call('ZRANGE', KEYS[1], 0, -1)) do
+	used = used + tonumber(string.match(member, ':(%d+)$') or '1')
+end
+return used
+`
+
+// logAdjustScript refunds ARGV[1] units from the newest entries of the log
+// at KEYS[1], shrinking the cost of the last entry it touches if needed
+const logAdjustScript = `
+local refund = tonumber(ARGV[1])
+local entries = redis.call('ZREVRANGE', KEYS[1], 0, -1, 'WITHSCORES')
+for i = 1, #entries, 2 do
+	if refund <= 0 then
+		break
+	end
+	local member = entries[i]
+	local cost = tonumber(string.match(member, ':(%d+)$') or '1')
+	redis.call('ZREM', KEYS[1], member)
+	if cost > refund then
+		local id = string.match(member, '^(.*):%d+$') or member
+		redis.call('ZADD', KEYS[1], entries[i + 1], id .. ':' .. (cost - refund))
+		refund = 0
+	else
+		refund = refund - cost
+	end
+end
+return 1
+`
+
+// logEntry is a request queued on a pipeline by queueLog
+type logEntry struct {
+	key     string
+	window  time.Duration
+	now     time.Time
+	member  string
+	evalCmd InterfaceCmd
+}
+
+// queueLog queues the sliding window log commands for a request costing n
+// units on pipe
+func (r *RedisRateLimiter) queueLog(ctx context.Context, pipe Pipeline, key string, window time.Duration, n int, now time.Time) *logEntry {
+	windowStart := now.Add(-window)
+	
+	// The request is logged as one entry carrying its cost
+	member := logMember(now, n)
+	evalCmd := pipe.Eval(ctx, slidingLogScript, []string{key},
+		fmt.Sprintf("%.0f", float64(windowStart.UnixNano())),
+		fmt.Sprintf("%.0f", float64(now.UnixNano())),
+		member, n)
+	
+	return &logEntry{
+		key:     key,
+		window:  window,
+		now:     now,
+		member:  member,
+		evalCmd: evalCmd,
+	}
+}
+
+// logResult builds the decision for an executed logEntry
+func (r *RedisRateLimiter) logResult(entry *logEntry, limit int) (*RateLimitResult, error) {
+	val, err := entry.evalCmd.Result()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get count: %w", err)
 	}
+	count, ok := val.(int64)
+	if !ok {
+		return nil, fmt.Errorf("unexpected sliding log reply: %v", val)
+	}
 	
 	// Calculate remaining requests
 	remaining := limit - int(count)
@@ -79,12 +200,12 @@ func (r *RedisRateLimiter) IsAllowed(ctx context.Context, key string, limit int,
 	}
 	
 	// Calculate reset time (next window)
-	resetTime := now.Add(window)
+	resetTime := entry.now.Add(entry.window)
 	
 	// Calculate retry after
 	retryAfter := time.Duration(0)
 	if count > int64(limit) {
-		retryAfter = window
+		retryAfter = entry.window
 	}
 	
 	return &RateLimitResult{
@@ -92,6 +213,124 @@ func (r *RedisRateLimiter) IsAllowed(ctx context.Context, key string, limit int,
 		Remaining:  remaining,
 		ResetTime:  resetTime,
 		RetryAfter: retryAfter,
+		Reservation: newReservation(func(ctx context.Context) error {
+			// Remove exactly the entry added for this request
+			if err := r.client.ZRem(ctx, entry.key, entry.member).Err(); err != nil {
+				return fmt.Errorf("failed to refund reservation: %w", err)
+			}
+			return nil
+		}),
+	}, nil
+}
+
+// rejectOversized turns the current state of a key into the rejection of a
+// request costing more than the limit. Such a request is not charged, so its
+// reservation has nothing to refund.
+func rejectOversized(result *RateLimitResult, err error) (*RateLimitResult, error) {
+	if err != nil {
+		return nil, err
+	}
+	result.Allowed = false
+	result.RetryAfter = time.Until(result.ResetTime)
+	result.Reservation = newReservation(func(ctx context.Context) error { return nil })
+	return result, nil
+}
+
+// Adjust charges delta additional units to key, or refunds delta units from
+// the most recent entries when delta is negative
+func (r *RedisRateLimiter) Adjust(ctx context.Context, key string, window time.Duration, delta int) error {
+	if delta == 0 {
+		return nil
+	}
+	
+	if delta < 0 {
+		if err := r.client.Eval(ctx, logAdjustScript, []string{key}, -delta).Err(); err != nil {
+			return fmt.Errorf("failed to refund cost: %w", err)
+		}
+		return nil
+	}
+	
+	now := time.Now()
+	if err := r.client.ZAdd(ctx, key, float64(now.UnixNano()), logMember(now, delta)).Err(); err != nil {
+		return fmt.Errorf("failed to charge cost: %w", err)
+	}
+	
+	r.client.Expire(ctx, key, window+time.Minute)
+	return nil
+}
+
+// Reset clears the request log of key
+func (r *RedisRateLimiter) Reset(ctx context.Context, key string) error {
+	if err := r.client.Del(ctx, key).Err(); err != nil {
+		return fmt.Errorf("failed to reset key: %w", err)
+	}
+	return nil
+}
+
+// logSeq keeps log members of requests logged at the same time unique
+var logSeq atomic.Uint64
+
+// logMember builds a unique sorted set member for a request costing n units
+func logMember(now time.Time, n int) string {
+	return fmt.Sprintf("%.0f-%d:%d", float64(now.UnixNano()), logSeq.Add(1), n)
+}
+
+// logCost returns the cost carried by a log member. Members written before
+// costs were stored in them count as one unit.
+func logCost(member string) int {
+	i := strings.LastIndexByte(member, ':')
+	if i < 0 {
+		return 1
+	}
+	n, err := strconv.Atoi(member[i+1:])
+	if err != nil {
+		return 1
+	}
+	return n
+}
+
+// Peek reports the state of a key without recording a request
+func (r *RedisRateLimiter) Peek(ctx context.Context, key string, limit int, window time.Duration) (*RateLimitResult, error) {
+	now := time.Now()
+	windowStart := now.Add(-window)
+	
+	pipe := r.client.Pipeline()
+	
+	// Remove old entries
+	pipe.ZRemRangeByScore(ctx, key, "0", fmt.Sprintf("%.0f", float64(windowStart.UnixNano())))
+	
+	// Sum the cost of the requests in window
+	membersCmd := pipe.ZRange(ctx, key, 0, -1)
+	
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, fmt.Errorf("redis pipeline error: %w", err)
+	}
+	
+	members, err := membersCmd.Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get count: %w", err)
+	}
+	count := int64(0)
+	for _, member := range members {
+		count += int64(logCost(member))
+	}
+	
+	remaining := limit - int(count)
+	if remaining < 0 {
+		remaining = 0
+	}
+	
+	// The next request would be rejected once the limit is reached
+	retryAfter := time.Duration(0)
+	if count >= int64(limit) {
+		retryAfter = window
+	}
+	
+	return &RateLimitResult{
+		Allowed:    count < int64(limit),
+		Remaining:  remaining,
+		ResetTime:  now.Add(window),
+		RetryAfter: retryAfter,
 	}, nil
 }
 
@@ -99,7 +338,9 @@ func (r *RedisRateLimiter) IsAllowed(ctx context.Context, key string, limit int,
 type RedisClient interface {
 	Get(ctx context.Context, key string) StringCmd
 	Set(ctx context.Context, key string, value interface{}, expiration time.Duration) StatusCmd
+	SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) BoolCmd
 	Incr(ctx context.Context, key string) IntCmd
+	IncrBy(ctx context.Context, key string, value int64) IntCmd
 	Expire(ctx context.Context, key string, expiration time.Duration) BoolCmd
 	Del(ctx context.Context, keys ...string) IntCmd
 	Close() error
@@ -108,18 +349,35 @@ type RedisClient interface {
 	HealthCheck(ctx context.Context) error
 	Pipeline() Pipeline
 	ZRemRangeByScore(ctx context.Context, key string, min, max string) IntCmd
+	ZRemRangeByRank(ctx context.Context, key string, start, stop int64) IntCmd
+	ZRem(ctx context.Context, key string, members ...interface{}) IntCmd
 	ZCard(ctx context.Context, key string) IntCmd
 	ZRange(ctx context.Context, key string, start, stop int64, args ...interface{}) StringSliceCmd
+	ZRevRange(ctx context.Context, key string, start, stop int64, args ...interface{}) StringSliceCmd
 	ZAdd(ctx context.Context, key string, score float64, member interface{}) IntCmd
+	ZIncrBy(ctx context.Context, key string, increment float64, member string) FloatCmd
 	ZCount(ctx context.Context, key string, min, max string) IntCmd
+	PFAdd(ctx context.Context, key string, els ...interface{}) IntCmd
+	PFCount(ctx context.Context, keys ...string) IntCmd
+	PFMerge(ctx context.Context, dest string, keys ...string) StatusCmd
+	Eval(ctx context.Context, script string, keys []string, args ...interface{}) InterfaceCmd
+	HIncrBy(ctx context.Context, key, field string, incr int64) IntCmd
+	HGetAll(ctx context.Context, key string) MapStringStringCmd
+	HSet(ctx context.Context, key string, values ...interface{}) IntCmd
+	Scan(ctx context.Context, cursor uint64, match string, count int64) ScanCmd
+	Type(ctx context.Context, key string) StatusCmd
 }
 
 // Pipeline interface - FIXED: Added missing ZAdd method
 type Pipeline interface {
+	Eval(ctx context.Context, script string, keys []string, args ...interface{}) InterfaceCmd
 	ZRemRangeByScore(ctx context.Context, key string, min, max string) IntCmd
 	ZCard(ctx context.Context, key string) IntCmd
 	ZRange(ctx context.Context, key string, start, stop int64, args ...interface{}) StringSliceCmd
 	ZAdd(ctx context.Context, key string, score float64, member interface{}) IntCmd  // <-- This was missing!
+	ZIncrBy(ctx context.Context, key string, increment float64, member string) FloatCmd
+	IncrBy(ctx context.Context, key string, value int64) IntCmd
+	Expire(ctx context.Context, key string, expiration time.Duration) BoolCmd
 	Exec(ctx context.Context) ([]Cmd, error)
 }
 
@@ -154,12 +412,35 @@ type DurationCmd interface {
 	Val() time.Duration
 }
 
+type FloatCmd interface {
+	Result() (float64, error)
+	Err() error
+	Val() float64
+}
+
 type StringSliceCmd interface {
 	Result() ([]string, error)
 	Err() error
 	Val() []string
 }
 
+type ScanCmd interface {
+	Result() (keys []string, cursor uint64, err error)
+	Err() error
+}
+
+type MapStringStringCmd interface {
+	Result() (map[string]string, error)
+	Err() error
+	Val() map[string]string
+}
+
+type InterfaceCmd interface {
+	Result() (interface{}, error)
+	Err() error
+	Val() interface{}
+}
+
 type Cmd interface {
 	Err() error
 }
@@ -0,0 +1,176 @@
+// internal/limitter/limiter_test.go
+package limitter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// costLimiter is a sliding log limiter under test
+type costLimiter interface {
+	CostLimiter
+	Peeker
+}
+
+// slidingLogs are the sliding log backends
+var slidingLogs = []backend[costLimiter]{
+	{"redis", func(t *testing.T) costLimiter {
+		_, client := newTestRedis(t)
+		return NewRedisRateLimiter(client, &Config{})
+	}},
+	{"batched", func(t *testing.T) costLimiter {
+		_, client := newTestRedis(t)
+		batched := NewBatchingRateLimiter(NewRedisRateLimiter(client, &Config{}), BatchConfig{})
+		t.Cleanup(batched.Close)
+		return batched
+	}},
+	{"memory", func(t *testing.T) costLimiter {
+		return NewMemoryRateLimiter()
+	}},
+}
+
+func TestSlidingLogCost(t *testing.T) {
+	tests := []struct {
+		name      string
+		costs     []int
+		allowed   []bool
+		remaining []int
+	}{
+		{
+			name:      "unit requests",
+			costs:     []int{1, 1, 1},
+			allowed:   []bool{true, true, true},
+			remaining: []int{9, 8, 7},
+		},
+		{
+			name:      "rejected requests are logged",
+			costs:     []int{4, 4, 4, 2},
+			allowed:   []bool{true, true, false, false},
+			remaining: []int{6, 2, 0, 0},
+		},
+		{
+			name:      "cost above the limit is rejected without being logged",
+			costs:     []int{1000000, 1},
+			allowed:   []bool{false, true},
+			remaining: []int{10, 9},
+		},
+		{
+			name:      "cost of the whole limit",
+			costs:     []int{10, 1},
+			allowed:   []bool{true, false},
+			remaining: []int{0, 0},
+		},
+		{
+			name:      "zero cost is free",
+			costs:     []int{0, 10, 0},
+			allowed:   []bool{true, true, true},
+			remaining: []int{10, 0, 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forEachBackend(t, slidingLogs, func(t *testing.T, limiter costLimiter) {
+				ctx := context.Background()
+				for i, cost := range tt.costs {
+					result, err := limiter.IsAllowedN(ctx, "rate_limit:test", 10, time.Minute, cost)
+					if err != nil {
+						t.Fatalf("request %d: %v", i, err)
+					}
+					if result.Allowed != tt.allowed[i] || result.Remaining != tt.remaining[i] {
+						t.Errorf("request %d costing %d: got allowed=%v remaining=%d, want allowed=%v remaining=%d",
+							i, cost, result.Allowed, result.Remaining, tt.allowed[i], tt.remaining[i])
+					}
+				}
+			})
+		})
+	}
+}
+
+func TestSlidingLogRejectsNegativeCost(t *testing.T) {
+	forEachBackend(t, slidingLogs, func(t *testing.T, limiter costLimiter) {
+		_, err := limiter.IsAllowedN(context.Background(), "rate_limit:test", 10, time.Minute, -5)
+		if !errors.Is(err, ErrInvalidCost) {
+			t.Fatalf("got %v, want ErrInvalidCost", err)
+		}
+	})
+}
+
+func TestSlidingLogStoresOneMemberPerRequest(t *testing.T) {
+	server, client := newTestRedis(t)
+	limiter := NewRedisRateLimiter(client, &Config{})
+	ctx := context.Background()
+
+	for _, cost := range []int{5, 500, 3, 4} {
+		if _, err := limiter.IsAllowedN(ctx, "rate_limit:test", 10, time.Minute, cost); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	members, err := server.ZMembers("rate_limit:test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The 500 unit request can never fit and is not logged, the rejected
+	// 4 unit request is
+	if len(members) != 3 {
+		t.Fatalf("got members %v, want one per logged request", members)
+	}
+}
+
+func TestSlidingLogAdjust(t *testing.T) {
+	tests := []struct {
+		name      string
+		costs     []int
+		delta     int
+		remaining int
+	}{
+		{name: "charge more", costs: []int{2}, delta: 3, remaining: 5},
+		{name: "refund part of the newest request", costs: []int{2, 4}, delta: -3, remaining: 7},
+		{name: "refund across requests", costs: []int{2, 4}, delta: -5, remaining: 9},
+		{name: "refund more than charged", costs: []int{2}, delta: -10, remaining: 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forEachBackend(t, slidingLogs, func(t *testing.T, limiter costLimiter) {
+				ctx := context.Background()
+				for _, cost := range tt.costs {
+					if _, err := limiter.IsAllowedN(ctx, "rate_limit:test", 10, time.Minute, cost); err != nil {
+						t.Fatal(err)
+					}
+				}
+				if err := limiter.Adjust(ctx, "rate_limit:test", time.Minute, tt.delta); err != nil {
+					t.Fatal(err)
+				}
+				result, err := limiter.Peek(ctx, "rate_limit:test", 10, time.Minute)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if result.Remaining != tt.remaining {
+					t.Errorf("got remaining %d, want %d", result.Remaining, tt.remaining)
+				}
+			})
+		})
+	}
+}
+
+func TestSlidingLogCountsMembersWithoutCost(t *testing.T) {
+	server, client := newTestRedis(t)
+	limiter := NewRedisRateLimiter(client, &Config{})
+
+	// Logs written before costs were stored hold one member per unit
+	now := float64(time.Now().UnixNano())
+	server.ZAdd("rate_limit:test", now, "1700000000000000000")
+	server.ZAdd("rate_limit:test", now, "1700000000000000000-1")
+
+	result, err := limiter.IsAllowed(context.Background(), "rate_limit:test", 10, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Remaining != 7 {
+		t.Errorf("got remaining %d, want 7", result.Remaining)
+	}
+}
@@ -0,0 +1,213 @@
+// internal/limitter/memory.go
+package limitter
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryRateLimiter implements the sliding window log in process memory.
+// Limits are not shared between instances.
+type MemoryRateLimiter struct {
+	mu        sync.Mutex
+	logs      map[string]*memoryLog
+	nextID    uint64
+	lastPrune time.Time
+}
+
+// memoryLog is the request log of one key
+type memoryLog struct {
+	entries []memoryEntry
+	window  time.Duration
+	expires time.Time
+}
+
+// memoryEntry is a logged request and its cost
+type memoryEntry struct {
+	at int64
+	id uint64
+	n  int
+}
+
+// NewMemoryRateLimiter creates a new in-memory rate limiter
+func NewMemoryRateLimiter() *MemoryRateLimiter {
+	return &MemoryRateLimiter{
+		logs:      make(map[string]*memoryLog),
+		lastPrune: time.Now(),
+	}
+}
+
+// IsAllowed checks if a request is allowed based on rate limits
+func (m *MemoryRateLimiter) IsAllowed(ctx context.Context, key string, limit int, window time.Duration) (*RateLimitResult, error) {
+	return m.IsAllowedN(ctx, key, limit, window, 1)
+}
+
+// IsAllowedN checks if a request costing n units is allowed. n must not be
+// negative. Like the Redis limiter, the request is logged even when it is
+// rejected, unless it costs more than limit and can never be allowed.
+func (m *MemoryRateLimiter) IsAllowedN(ctx context.Context, key string, limit int, window time.Duration, n int) (*RateLimitResult, error) {
+	if n < 0 {
+		return nil, ErrInvalidCost
+	}
+	if n > limit {
+		return rejectOversized(m.Peek(ctx, key, limit, window))
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	m.pruneLocked(now)
+
+	log := m.logLocked(key, now, window)
+	m.nextID++
+	id := m.nextID
+	if n > 0 {
+		log.entries = append(log.entries, memoryEntry{at: now.UnixNano(), id: id, n: n})
+	}
+	count := log.used()
+
+	remaining := limit - count
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	retryAfter := time.Duration(0)
+	if count > limit {
+		retryAfter = window
+	}
+
+	return &RateLimitResult{
+		Allowed:    count <= limit,
+		Remaining:  remaining,
+		ResetTime:  now.Add(window),
+		RetryAfter: retryAfter,
+		Reservation: newReservation(func(ctx context.Context) error {
+			m.remove(key, id)
+			return nil
+		}),
+	}, nil
+}
+
+// Adjust charges delta additional units to key, or refunds delta units from
+// the most recent entries when delta is negative
+func (m *MemoryRateLimiter) Adjust(ctx context.Context, key string, window time.Duration, delta int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	log := m.logLocked(key, now, window)
+	if delta < 0 {
+		refund := -delta
+		for len(log.entries) > 0 && refund > 0 {
+			last := &log.entries[len(log.entries)-1]
+			if last.n > refund {
+				last.n -= refund
+				break
+			}
+			refund -= last.n
+			log.entries = log.entries[:len(log.entries)-1]
+		}
+		return nil
+	}
+	if delta > 0 {
+		m.nextID++
+		log.entries = append(log.entries, memoryEntry{at: now.UnixNano(), id: m.nextID, n: delta})
+	}
+	return nil
+}
+
+// Peek reports the state of a key without recording a request
+func (m *MemoryRateLimiter) Peek(ctx context.Context, key string, limit int, window time.Duration) (*RateLimitResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	count := 0
+	if log, ok := m.logs[key]; ok {
+		log.trim(now.Add(-window).UnixNano())
+		count = log.used()
+	}
+
+	return &RateLimitResult{
+		Allowed:   count < limit,
+		Remaining: max(limit-count, 0),
+		ResetTime: now.Add(window),
+	}, nil
+}
+
+// Reset clears the request log of key
+func (m *MemoryRateLimiter) Reset(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.logs, key)
+	return nil
+}
+
+// logLocked returns the trimmed log of key, creating it if needed. m.mu must
+// be held.
+func (m *MemoryRateLimiter) logLocked(key string, now time.Time, window time.Duration) *memoryLog {
+	log, ok := m.logs[key]
+	if !ok {
+		log = &memoryLog{}
+		m.logs[key] = log
+	}
+	log.trim(now.Add(-window).UnixNano())
+	log.window = window
+	log.expires = now.Add(window)
+	return log
+}
+
+// remove drops the entry with the given id from the log of key
+func (m *MemoryRateLimiter) remove(key string, id uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	log, ok := m.logs[key]
+	if !ok {
+		return
+	}
+
+	kept := log.entries[:0]
+	for _, e := range log.entries {
+		if e.id != id {
+			kept = append(kept, e)
+		}
+	}
+	log.entries = kept
+}
+
+// pruneLocked drops the logs of idle keys now and then. m.mu must be held.
+func (m *MemoryRateLimiter) pruneLocked(now time.Time) {
+	if now.Sub(m.lastPrune) < time.Minute {
+		return
+	}
+	for key, log := range m.logs {
+		if now.After(log.expires) {
+			delete(m.logs, key)
+		}
+	}
+	m.lastPrune = now
+}
+
+// trim removes entries at or before windowStart. Entries are kept in
+// insertion order, which is also time order.
+func (l *memoryLog) trim(windowStart int64) {
+	i := 0
+	for i < len(l.entries) && l.entries[i].at <= windowStart {
+		i++
+	}
+	if i > 0 {
+		l.entries = append(l.entries[:0], l.entries[i:]...)
+	}
+}
+
+// used sums the cost of the entries in the log
+func (l *memoryLog) used() int {
+	used := 0
+	for _, e := range l.entries {
+		used += e.n
+	}
+	return used
+}
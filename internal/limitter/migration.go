@@ -0,0 +1,216 @@
+// internal/limitter/migration.go
+package limitter
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Algorithm names a rate limiting algorithm and its key format
+type Algorithm string
+
+const (
+	// AlgorithmSlidingLog is the sorted set log of RedisRateLimiter
+	AlgorithmSlidingLog Algorithm = "sliding_log"
+	// AlgorithmGCRA is the single timestamp of GCRARateLimiter
+	AlgorithmGCRA Algorithm = "gcra"
+	// AlgorithmTokenBucket is the token hash of BucketRateLimiter
+	AlgorithmTokenBucket Algorithm = "token_bucket"
+)
+
+// keyFormatVersion is the current version of the versioned key formats. Bump
+// it when the state an algorithm stores changes shape.
+const keyFormatVersion = 1
+
+// VersionedKey returns the key algorithm stores the state of key under. The
+// sliding log predates versioning and keeps the bare key.
+func VersionedKey(key string, algorithm Algorithm) string {
+	if algorithm == AlgorithmSlidingLog {
+		return key
+	}
+	return fmt.Sprintf("%s:%s:v%d", key, algorithm, keyFormatVersion)
+}
+
+// isVersionedKey reports whether key is in a versioned key format
+func isVersionedKey(key string) bool {
+	for _, algorithm := range []Algorithm{AlgorithmGCRA, AlgorithmTokenBucket} {
+		if strings.HasSuffix(key, fmt.Sprintf(":%s:v%d", algorithm, keyFormatVersion)) {
+			return true
+		}
+	}
+	return false
+}
+
+// MigrationTarget is implemented by the limiters a sliding log can be
+// migrated to. It is sealed: only GCRARateLimiter and BucketRateLimiter
+// implement it.
+type MigrationTarget interface {
+	RateLimiter
+	CostLimiter
+	Algorithm() Algorithm
+	versionedKey(key string) string
+	// decide charges n units to key. If key has no state yet and legacyKey
+	// is set, the state is first converted from the log at legacyKey.
+	// Reports whether key was migrated.
+	decide(ctx context.Context, key, legacyKey string, limit int, window time.Duration, n int) (*RateLimitResult, bool, error)
+}
+
+// MigrationReport shows the progress of a migration off the sliding log
+type MigrationReport struct {
+	Algorithm Algorithm `json:"algorithm"`
+	// Sliding logs still in Redis, migrated or not
+	Legacy int `json:"legacy_keys"`
+	// Sliding logs whose key already has state in the new format
+	Migrated int `json:"migrated_keys"`
+	Pending  int `json:"pending_keys"`
+	// Percent of the sliding logs that are migrated
+	Percent float64 `json:"percent"`
+	// Keys migrated on first access by this instance
+	MigratedOnAccess int64 `json:"migrated_on_access"`
+}
+
+// MigratingRateLimiter switches a policy from the sliding log to GCRA or the
+// token bucket without resetting anyone. It decides with the new algorithm
+// and, the first time it sees a key without new-format state, converts the
+// key's sliding log so requests already made in the window still count. The
+// old logs are left to expire, so the switch can be rolled back.
+type MigratingRateLimiter struct {
+	client   RedisClient
+	target   MigrationTarget
+	migrated atomic.Int64
+}
+
+// NewMigratingRateLimiter creates a limiter deciding with target and reading
+// the sliding logs it replaces. target is a *GCRARateLimiter or a
+// *BucketRateLimiter.
+func NewMigratingRateLimiter(client RedisClient, target MigrationTarget) *MigratingRateLimiter {
+	return &MigratingRateLimiter{client: client, target: target}
+}
+
+// IsAllowed checks if a request is allowed based on rate limits
+func (m *MigratingRateLimiter) IsAllowed(ctx context.Context, key string, limit int, window time.Duration) (*RateLimitResult, error) {
+	return m.IsAllowedN(ctx, key, limit, window, 1)
+}
+
+// IsAllowedN checks if a request costing n units is allowed, migrating key on
+// its first access
+func (m *MigratingRateLimiter) IsAllowedN(ctx context.Context, key string, limit int, window time.Duration, n int) (*RateLimitResult, error) {
+	result, migrated, err := m.target.decide(ctx, key, key, limit, window, n)
+	if err != nil {
+		return nil, err
+	}
+	if migrated {
+		m.migrated.Add(1)
+	}
+	return result, nil
+}
+
+// Adjust implements CostLimiter. Keys are migrated by their first decision,
+// so only the new format is adjusted.
+func (m *MigratingRateLimiter) Adjust(ctx context.Context, key string, window time.Duration, delta int) error {
+	return m.target.Adjust(ctx, key, window, delta)
+}
+
+// Reset clears key in both the old and the new format
+func (m *MigratingRateLimiter) Reset(ctx context.Context, key string) error {
+	if err := m.client.Del(ctx, key, m.target.versionedKey(key)).Err(); err != nil {
+		return fmt.Errorf("failed to reset key: %w", err)
+	}
+	return nil
+}
+
+// Migrate converts the sliding log of key without charging a request.
+// Reports whether key was migrated; keys that already have new-format state
+// are left alone.
+func (m *MigratingRateLimiter) Migrate(ctx context.Context, key string, limit int, window time.Duration) (bool, error) {
+	_, migrated, err := m.target.decide(ctx, key, key, limit, window, 0)
+	if err != nil {
+		return false, fmt.Errorf("failed to migrate %s: %w", key, err)
+	}
+	if migrated {
+		m.migrated.Add(1)
+	}
+	return migrated, nil
+}
+
+// MigrateAll converts every sliding log starting with prefix, using the
+// limit and window of its policy. Returns the progress afterwards.
+func (m *MigratingRateLimiter) MigrateAll(ctx context.Context, prefix string, limit int, window time.Duration) (*MigrationReport, error) {
+	err := m.scanLogs(ctx, prefix, func(key string) error {
+		_, err := m.Migrate(ctx, key, limit, window)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return m.Progress(ctx, prefix)
+}
+
+// Progress counts the sliding logs starting with prefix and how many of them
+// have been migrated
+func (m *MigratingRateLimiter) Progress(ctx context.Context, prefix string) (*MigrationReport, error) {
+	report := &MigrationReport{
+		Algorithm:        m.target.Algorithm(),
+		MigratedOnAccess: m.migrated.Load(),
+	}
+
+	err := m.scanLogs(ctx, prefix, func(key string) error {
+		keyType, err := m.client.Type(ctx, m.target.versionedKey(key)).Result()
+		if err != nil {
+			return fmt.Errorf("failed to get type of %s: %w", key, err)
+		}
+		report.Legacy++
+		if keyType != "none" {
+			report.Migrated++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	report.Pending = report.Legacy - report.Migrated
+	if report.Legacy > 0 {
+		report.Percent = float64(report.Migrated) * 100 / float64(report.Legacy)
+	}
+	return report, nil
+}
+
+// scanLogs calls fn with every sliding log starting with prefix
+func (m *MigratingRateLimiter) scanLogs(ctx context.Context, prefix string, fn func(key string) error) error {
+	seen := make(map[string]bool)
+	var cursor uint64
+	for {
+		keys, next, err := m.client.Scan(ctx, cursor, prefix+"*", 100).Result()
+		if err != nil {
+			return fmt.Errorf("failed to scan keys: %w", err)
+		}
+
+		for _, key := range keys {
+			// SCAN may return a key more than once
+			if seen[key] || isVersionedKey(key) {
+				continue
+			}
+			seen[key] = true
+
+			keyType, err := m.client.Type(ctx, key).Result()
+			if err != nil {
+				return fmt.Errorf("failed to get type of %s: %w", key, err)
+			}
+			if keyType != "zset" {
+				continue
+			}
+			if err := fn(key); err != nil {
+				return err
+			}
+		}
+
+		cursor = next
+		if cursor == 0 {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,156 @@
+// internal/limitter/migration_test.go
+package limitter
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// migrationSetup is a migration target sharing its Redis with the sliding
+// log it replaces
+type migrationSetup struct {
+	client RedisClient
+	target MigrationTarget
+}
+
+var migrationTargets = []backend[migrationSetup]{
+	{"gcra", func(t *testing.T) migrationSetup {
+		_, client := newTestRedis(t)
+		return migrationSetup{client, NewGCRARateLimiter(client)}
+	}},
+	{"token_bucket", func(t *testing.T) migrationSetup {
+		_, client := newTestRedis(t)
+		return migrationSetup{client, NewBucketRateLimiter(client)}
+	}},
+}
+
+func TestMigrateSlidingLog(t *testing.T) {
+	tests := []struct {
+		name string
+		// costs of requests logged by the sliding log limiter
+		costs []int
+		// members written directly, with their age
+		raw       map[string]time.Duration
+		migrated  bool
+		remaining int
+	}{
+		{name: "single requests", costs: []int{1, 1, 1}, migrated: true, remaining: 7},
+		{name: "requests with costs", costs: []int{3, 4}, migrated: true, remaining: 3},
+		{name: "exhausted log", costs: []int{10}, migrated: true, remaining: 0},
+		{name: "members without a cost count once", raw: map[string]time.Duration{"1760000000000000001": time.Second, "1760000000000000002": time.Second}, migrated: true, remaining: 8},
+		{name: "requests outside the window are ignored", costs: []int{2}, raw: map[string]time.Duration{"1-1:5": 2 * time.Minute}, migrated: true, remaining: 8},
+		{name: "no log", migrated: false, remaining: 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forEachBackend(t, migrationTargets, func(t *testing.T, setup migrationSetup) {
+				ctx := context.Background()
+				client, target := setup.client, setup.target
+				log := NewRedisRateLimiter(client, &Config{})
+				for _, cost := range tt.costs {
+					if _, err := log.IsAllowedN(ctx, "rate_limit:test", 10, time.Minute, cost); err != nil {
+						t.Fatal(err)
+					}
+				}
+				for member, age := range tt.raw {
+					if err := client.ZAdd(ctx, "rate_limit:test", float64(time.Now().Add(-age).UnixNano()), member).Err(); err != nil {
+						t.Fatal(err)
+					}
+				}
+
+				migration := NewMigratingRateLimiter(client, target)
+				migrated, err := migration.Migrate(ctx, "rate_limit:test", 10, time.Minute)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if migrated != tt.migrated {
+					t.Errorf("got migrated=%v, want %v", migrated, tt.migrated)
+				}
+
+				if got := remaining(t, target, "rate_limit:test", 10); got != tt.remaining {
+					t.Errorf("got remaining %d after migration, want %d", got, tt.remaining)
+				}
+
+				// Migrating again leaves the new state alone
+				if migrated, err := migration.Migrate(ctx, "rate_limit:test", 10, time.Minute); err != nil || migrated {
+					t.Errorf("got migrated=%v err=%v on the second migration, want neither", migrated, err)
+				}
+			})
+		})
+	}
+}
+
+func TestMigrateOnFirstAccess(t *testing.T) {
+	tests := []struct {
+		name      string
+		logged    int
+		costs     []int
+		allowed   []bool
+		remaining []int
+	}{
+		{name: "usage carries over", logged: 3, costs: []int{2, 1}, allowed: []bool{true, true}, remaining: []int{5, 4}},
+		{name: "exhausted clients stay denied", logged: 9, costs: []int{2, 1}, allowed: []bool{false, true}, remaining: []int{1, 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forEachBackend(t, migrationTargets, func(t *testing.T, setup migrationSetup) {
+				ctx := context.Background()
+				client := setup.client
+				if _, err := NewRedisRateLimiter(client, &Config{}).IsAllowedN(ctx, "rate_limit:test", 10, time.Minute, tt.logged); err != nil {
+					t.Fatal(err)
+				}
+
+				migration := NewMigratingRateLimiter(client, setup.target)
+				for i, cost := range tt.costs {
+					result, err := migration.IsAllowedN(ctx, "rate_limit:test", 10, time.Minute, cost)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if result.Allowed != tt.allowed[i] || result.Remaining != tt.remaining[i] {
+						t.Fatalf("request %d: got allowed=%v remaining=%d, want allowed=%v remaining=%d",
+							i, result.Allowed, result.Remaining, tt.allowed[i], tt.remaining[i])
+					}
+				}
+
+				report, err := migration.Progress(ctx, "rate_limit:")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if report.Legacy != 1 || report.Migrated != 1 || report.MigratedOnAccess != 1 {
+					t.Errorf("got report %+v, want one legacy key migrated on access", report)
+				}
+			})
+		})
+	}
+}
+
+func TestMigrateAll(t *testing.T) {
+	forEachBackend(t, migrationTargets, func(t *testing.T, setup migrationSetup) {
+		ctx := context.Background()
+		client, target := setup.client, setup.target
+		log := NewRedisRateLimiter(client, &Config{})
+		for i := 0; i < 3; i++ {
+			if _, err := log.IsAllowedN(ctx, fmt.Sprintf("rate_limit:ip:10.0.0.%d", i), 10, time.Minute, i+1); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		report, err := NewMigratingRateLimiter(client, target).MigrateAll(ctx, "rate_limit:ip:", 10, time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if report.Legacy != 3 || report.Pending != 0 || report.Percent != 100 {
+			t.Errorf("got report %+v, want all 3 keys migrated", report)
+		}
+		for i := 0; i < 3; i++ {
+			key := fmt.Sprintf("rate_limit:ip:10.0.0.%d", i)
+			if got := remaining(t, target, key, 10); got != 10-(i+1) {
+				t.Errorf("%s: got remaining %d, want %d", key, got, 10-(i+1))
+			}
+		}
+	})
+}
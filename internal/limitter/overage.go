@@ -0,0 +1,100 @@
+// internal/limitter/overage.go
+package limitter
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// OverageConfig holds configuration for overage recording
+type OverageConfig struct {
+	// Time layout naming a billing period (default monthly, "2006-01")
+	PeriodFormat string
+	// How long recorded periods are kept
+	Retention time.Duration
+	// Key prefix for Redis keys
+	KeyPrefix string
+}
+
+// OverageRecord is the overage of one key in one billing period
+type OverageRecord struct {
+	Policy string `json:"policy"`
+	Period string `json:"period"`
+	Key    string `json:"key"`
+	Count  int64  `json:"count"`
+}
+
+// OverageRecorder stores requests served above a soft limit per key and
+// billing period so they can be exported for billing
+type OverageRecorder struct {
+	client RedisClient
+	config OverageConfig
+}
+
+// NewOverageRecorder creates a new Redis-based overage recorder
+func NewOverageRecorder(client RedisClient, config OverageConfig) *OverageRecorder {
+	if config.PeriodFormat == "" {
+		config.PeriodFormat = "2006-01"
+	}
+	if config.Retention <= 0 {
+		config.Retention = 400 * 24 * time.Hour
+	}
+	if config.KeyPrefix == "" {
+		config.KeyPrefix = "rate_limit:overage:"
+	}
+
+	return &OverageRecorder{
+		client: client,
+		config: config,
+	}
+}
+
+// Record adds count overage units for key under policy in the current period
+func (o *OverageRecorder) Record(ctx context.Context, policy, key string, count int64) error {
+	periodKey := o.periodKey(policy, o.Period(time.Now()))
+
+	if err := o.client.HIncrBy(ctx, periodKey, key, count).Err(); err != nil {
+		return fmt.Errorf("failed to record overage: %w", err)
+	}
+	o.client.Expire(ctx, periodKey, o.config.Retention)
+	return nil
+}
+
+// Export returns the overage of every key under policy in period, sorted by key
+func (o *OverageRecorder) Export(ctx context.Context, policy, period string) ([]OverageRecord, error) {
+	values, err := o.client.HGetAll(ctx, o.periodKey(policy, period)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to export overage: %w", err)
+	}
+
+	records := make([]OverageRecord, 0, len(values))
+	for key, val := range values {
+		count, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid overage count %q: %w", val, err)
+		}
+		records = append(records, OverageRecord{
+			Policy: policy,
+			Period: period,
+			Key:    key,
+			Count:  count,
+		})
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Key < records[j].Key
+	})
+	return records, nil
+}
+
+// Period returns the name of the billing period containing t
+func (o *OverageRecorder) Period(t time.Time) string {
+	return t.UTC().Format(o.config.PeriodFormat)
+}
+
+func (o *OverageRecorder) periodKey(policy, period string) string {
+	return o.config.KeyPrefix + policy + ":" + period
+}
@@ -0,0 +1,405 @@
+// internal/limitter/peer.go
+package limitter
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// PeerConfig holds configuration for peer-to-peer limiting
+type PeerConfig struct {
+	// Base URL other peers use to reach this instance (required)
+	Self string
+	// Base URLs of all peers, with or without Self
+	Peers []string
+	// Path the internal API is served under (default "/internal/ratelimit")
+	PathPrefix string
+	// Shared secret sent in the X-Peer-Token header. Without it anyone who
+	// can reach the internal API can charge and refund keys (optional).
+	Token string
+	// Virtual nodes per peer on the hash ring (default 100)
+	Replicas int
+	// How often peers are health checked (default 2s)
+	HealthInterval time.Duration
+	// Timeout for a call to another peer (default 500ms)
+	Timeout time.Duration
+	// Called with the live peers whenever a peer joins or leaves (optional)
+	OnMembershipChange func(alive []string)
+}
+
+// PeerRateLimiter limits without a shared store. Every key is owned by one
+// live peer, chosen by consistent hashing, which decides with its in-memory
+// limiter. Other peers forward decisions to the owner over HTTP and cache
+// its denials until the key resets. When a peer leaves, its keys move to
+// other peers and start from zero there.
+type PeerRateLimiter struct {
+	config  PeerConfig
+	client  *http.Client
+	local   *MemoryRateLimiter
+	denials *DenialCache
+
+	mu    sync.RWMutex
+	ring  *hashRing
+	alive []string
+
+	resMu        sync.Mutex
+	reservations map[string]peerReservation
+}
+
+// peerReservation is a charge made on behalf of another peer
+type peerReservation struct {
+	reservation Reservation
+	expires     time.Time
+}
+
+// peerDecision is the request and response body of the internal API
+type peerDecision struct {
+	Key         string `json:"key"`
+	Limit       int    `json:"limit,omitempty"`
+	WindowMs    int64  `json:"window_ms,omitempty"`
+	N           int    `json:"n,omitempty"`
+	Allowed     bool   `json:"allowed"`
+	Remaining   int    `json:"remaining"`
+	ResetUnixMs int64  `json:"reset_unix_ms"`
+	RetryMs     int64  `json:"retry_after_ms"`
+	Reservation string `json:"reservation,omitempty"`
+}
+
+// NewPeerRateLimiter creates a peer-to-peer rate limiter. Every peer starts
+// out alive; call Run to start health checks.
+func NewPeerRateLimiter(config PeerConfig) *PeerRateLimiter {
+	if config.PathPrefix == "" {
+		config.PathPrefix = "/internal/ratelimit"
+	}
+	if config.Replicas <= 0 {
+		config.Replicas = 100
+	}
+	if config.HealthInterval <= 0 {
+		config.HealthInterval = 2 * time.Second
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 500 * time.Millisecond
+	}
+	if !slices.Contains(config.Peers, config.Self) {
+		config.Peers = append(slices.Clone(config.Peers), config.Self)
+	}
+
+	p := &PeerRateLimiter{
+		config:       config,
+		client:       &http.Client{Timeout: config.Timeout},
+		local:        NewMemoryRateLimiter(),
+		denials:      NewDenialCache(0),
+		reservations: make(map[string]peerReservation),
+	}
+	p.setAlive(slices.Clone(config.Peers))
+	return p
+}
+
+// IsAllowed checks if a request is allowed
+func (p *PeerRateLimiter) IsAllowed(ctx context.Context, key string, limit int, window time.Duration) (*RateLimitResult, error) {
+	return p.IsAllowedN(ctx, key, limit, window, 1)
+}
+
+// IsAllowedN checks if a request costing n units is allowed, asking the
+// owner of key when that is another peer
+func (p *PeerRateLimiter) IsAllowedN(ctx context.Context, key string, limit int, window time.Duration, n int) (*RateLimitResult, error) {
+	owner := p.Owner(key)
+	if owner == p.config.Self {
+		return p.local.IsAllowedN(ctx, key, limit, window, n)
+	}
+
+	// Keys the owner denied are rejected without asking again
+	if until, denied := p.denials.Denied(key); denied {
+		return &RateLimitResult{
+			Allowed:    false,
+			Remaining:  0,
+			ResetTime:  until,
+			RetryAfter: time.Until(until),
+		}, nil
+	}
+
+	var reply peerDecision
+	err := p.call(ctx, owner, "/allow", peerDecision{
+		Key:      key,
+		Limit:    limit,
+		WindowMs: window.Milliseconds(),
+		N:        n,
+	}, &reply)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &RateLimitResult{
+		Allowed:    reply.Allowed,
+		Remaining:  reply.Remaining,
+		ResetTime:  time.UnixMilli(reply.ResetUnixMs),
+		RetryAfter: time.Duration(reply.RetryMs) * time.Millisecond,
+	}
+	if !result.Allowed && result.Remaining == 0 {
+		p.denials.Deny(key, result.ResetTime)
+	}
+	if reply.Reservation != "" {
+		id := reply.Reservation
+		result.Reservation = newReservation(func(ctx context.Context) error {
+			return p.call(ctx, owner, "/refund", peerDecision{Key: key, Reservation: id}, nil)
+		})
+	}
+	return result, nil
+}
+
+// Adjust implements CostLimiter, adjusting the charge of key on its owner
+func (p *PeerRateLimiter) Adjust(ctx context.Context, key string, window time.Duration, delta int) error {
+	if delta == 0 {
+		return nil
+	}
+
+	owner := p.Owner(key)
+	if owner == p.config.Self {
+		return p.local.Adjust(ctx, key, window, delta)
+	}
+	return p.call(ctx, owner, "/adjust", peerDecision{Key: key, WindowMs: window.Milliseconds(), N: delta}, nil)
+}
+
+// Owner returns the live peer that owns key
+func (p *PeerRateLimiter) Owner(key string) string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.ring.owner(key)
+}
+
+// Local returns the in-memory limiter deciding for the keys this peer owns
+func (p *PeerRateLimiter) Local() *MemoryRateLimiter {
+	return p.local
+}
+
+// Alive returns the peers that passed their last health check
+func (p *PeerRateLimiter) Alive() []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return slices.Clone(p.alive)
+}
+
+// Run health checks the peers every HealthInterval until ctx is cancelled
+func (p *PeerRateLimiter) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.config.HealthInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.checkPeers(ctx)
+		}
+	}
+}
+
+// checkPeers updates the live peers and rebuilds the ring on changes
+func (p *PeerRateLimiter) checkPeers(ctx context.Context) {
+	alive := make([]string, 0, len(p.config.Peers))
+	for _, peer := range p.config.Peers {
+		if peer == p.config.Self || p.call(ctx, peer, "/health", nil, nil) == nil {
+			alive = append(alive, peer)
+		}
+	}
+
+	if slices.Equal(alive, p.Alive()) {
+		return
+	}
+	p.setAlive(alive)
+	if p.config.OnMembershipChange != nil {
+		p.config.OnMembershipChange(slices.Clone(alive))
+	}
+}
+
+// setAlive replaces the live peers and their ring
+func (p *PeerRateLimiter) setAlive(alive []string) {
+	ring := newHashRing(alive, p.config.Replicas)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.alive = alive
+	p.ring = ring
+}
+
+// Handler serves the internal API other peers call under PathPrefix
+func (p *PeerRateLimiter) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+p.config.PathPrefix+"/health", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("POST "+p.config.PathPrefix+"/allow", p.serveAllow)
+	mux.HandleFunc("POST "+p.config.PathPrefix+"/refund", p.serveRefund)
+	mux.HandleFunc("POST "+p.config.PathPrefix+"/adjust", p.serveAdjust)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if p.config.Token != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get("X-Peer-Token")), []byte(p.config.Token)) != 1 {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// serveAllow decides for a key this peer owns
+func (p *PeerRateLimiter) serveAllow(w http.ResponseWriter, r *http.Request) {
+	var req peerDecision
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Key == "" || req.WindowMs <= 0 {
+		http.Error(w, "invalid decision request", http.StatusBadRequest)
+		return
+	}
+
+	window := time.Duration(req.WindowMs) * time.Millisecond
+	result, err := p.local.IsAllowedN(r.Context(), req.Key, req.Limit, window, max(req.N, 1))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	reply := peerDecision{
+		Key:         req.Key,
+		Allowed:     result.Allowed,
+		Remaining:   result.Remaining,
+		ResetUnixMs: result.ResetTime.UnixMilli(),
+		RetryMs:     result.RetryAfter.Milliseconds(),
+	}
+	if result.Reservation != nil {
+		reply.Reservation = p.holdReservation(result.Reservation, window)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(reply)
+}
+
+// serveRefund refunds a charge made by serveAllow
+func (p *PeerRateLimiter) serveRefund(w http.ResponseWriter, r *http.Request) {
+	var req peerDecision
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid refund request", http.StatusBadRequest)
+		return
+	}
+
+	p.resMu.Lock()
+	held, ok := p.reservations[req.Reservation]
+	delete(p.reservations, req.Reservation)
+	p.resMu.Unlock()
+
+	if ok {
+		held.reservation.Refund(r.Context())
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// serveAdjust adjusts the charge of a key this peer owns by N units
+func (p *PeerRateLimiter) serveAdjust(w http.ResponseWriter, r *http.Request) {
+	var req peerDecision
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Key == "" || req.WindowMs <= 0 {
+		http.Error(w, "invalid adjust request", http.StatusBadRequest)
+		return
+	}
+
+	if err := p.local.Adjust(r.Context(), req.Key, time.Duration(req.WindowMs)*time.Millisecond, req.N); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// holdReservation keeps a reservation for a later refund until its window
+// ends. Its ID is random so that it can't be guessed to refund other charges.
+func (p *PeerRateLimiter) holdReservation(reservation Reservation, window time.Duration) string {
+	p.resMu.Lock()
+	defer p.resMu.Unlock()
+
+	now := time.Now()
+	for id, held := range p.reservations {
+		if now.After(held.expires) {
+			delete(p.reservations, id)
+		}
+	}
+
+	id := rand.Text()
+	p.reservations[id] = peerReservation{reservation: reservation, expires: now.Add(window)}
+	return id
+}
+
+// call posts body to a peer's internal API and decodes the reply into out
+func (p *PeerRateLimiter) call(ctx context.Context, peer, path string, body interface{}, out interface{}) error {
+	method := http.MethodGet
+	var payload bytes.Buffer
+	if body != nil {
+		method = http.MethodPost
+		if err := json.NewEncoder(&payload).Encode(body); err != nil {
+			return fmt.Errorf("failed to encode peer request: %w", err)
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, peer+p.config.PathPrefix+path, &payload)
+	if err != nil {
+		return fmt.Errorf("failed to build peer request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.config.Token != "" {
+		req.Header.Set("X-Peer-Token", p.config.Token)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("peer %s unreachable: %w", peer, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("peer %s returned status %d", peer, resp.StatusCode)
+	}
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return fmt.Errorf("failed to decode peer reply: %w", err)
+		}
+	}
+	return nil
+}
+
+// hashRing maps keys to peers by consistent hashing
+type hashRing struct {
+	hashes []uint32
+	peers  map[uint32]string
+}
+
+// newHashRing places replicas virtual nodes of every peer on the ring
+func newHashRing(peers []string, replicas int) *hashRing {
+	ring := &hashRing{peers: make(map[uint32]string, len(peers)*replicas)}
+	for _, peer := range peers {
+		for i := 0; i < replicas; i++ {
+			h := crc32.ChecksumIEEE([]byte(strconv.Itoa(i) + peer))
+			ring.hashes = append(ring.hashes, h)
+			ring.peers[h] = peer
+		}
+	}
+	slices.Sort(ring.hashes)
+	return ring
+}
+
+// owner returns the peer of the first virtual node at or after key's hash
+func (r *hashRing) owner(key string) string {
+	if len(r.hashes) == 0 {
+		return ""
+	}
+	h := crc32.ChecksumIEEE([]byte(key))
+	i := sort.Search(len(r.hashes), func(i int) bool { return r.hashes[i] >= h })
+	if i == len(r.hashes) {
+		i = 0
+	}
+	return r.peers[r.hashes[i]]
+}
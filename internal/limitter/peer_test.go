@@ -0,0 +1,147 @@
+// internal/limitter/peer_test.go
+package limitter
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHashRingOwner(t *testing.T) {
+	tests := []struct {
+		name  string
+		peers []string
+	}{
+		{name: "no peers"},
+		{name: "one peer", peers: []string{"http://a"}},
+		{name: "three peers", peers: []string{"http://a", "http://b", "http://c"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ring := newHashRing(tt.peers, 100)
+			owners := make(map[string]int)
+			for i := 0; i < 3000; i++ {
+				key := fmt.Sprintf("rate_limit:ip:10.0.%d.%d", i/256, i%256)
+				owner := ring.owner(key)
+				if owner != ring.owner(key) {
+					t.Fatalf("key %s has no stable owner", key)
+				}
+				owners[owner]++
+			}
+
+			if len(tt.peers) == 0 {
+				if owners[""] != 3000 {
+					t.Errorf("got owners %v, want none", owners)
+				}
+				return
+			}
+			// Virtual nodes spread keys over every peer
+			for _, peer := range tt.peers {
+				if share := owners[peer]; share < 3000/len(tt.peers)/3 {
+					t.Errorf("peer %s owns %d of 3000 keys", peer, share)
+				}
+			}
+		})
+	}
+}
+
+func TestHashRingMovesOnlyDepartedKeys(t *testing.T) {
+	tests := []struct {
+		name     string
+		peers    []string
+		departed string
+	}{
+		{name: "first of three", peers: []string{"http://a", "http://b", "http://c"}, departed: "http://a"},
+		{name: "last of four", peers: []string{"http://a", "http://b", "http://c", "http://d"}, departed: "http://d"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := newHashRing(tt.peers, 100)
+			var rest []string
+			for _, peer := range tt.peers {
+				if peer != tt.departed {
+					rest = append(rest, peer)
+				}
+			}
+			after := newHashRing(rest, 100)
+
+			for i := 0; i < 1000; i++ {
+				key := fmt.Sprintf("rate_limit:token:%d", i)
+				old, moved := before.owner(key), after.owner(key)
+				if old != tt.departed && old != moved {
+					t.Fatalf("key %s moved from %s to %s", key, old, moved)
+				}
+				if moved == tt.departed {
+					t.Fatalf("key %s is still owned by the departed peer", key)
+				}
+			}
+		})
+	}
+}
+
+func TestPeerHandlerToken(t *testing.T) {
+	tests := []struct {
+		name   string
+		token  string
+		header string
+		status int
+	}{
+		{name: "matching token", token: "secret", header: "secret", status: http.StatusNoContent},
+		{name: "wrong token", token: "secret", header: "guess", status: http.StatusForbidden},
+		{name: "missing token", token: "secret", status: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			peer := NewPeerRateLimiter(PeerConfig{Self: "http://self", Token: tt.token})
+			req := httptest.NewRequest(http.MethodPost, "/internal/ratelimit/refund", strings.NewReader(`{"reservation":"x"}`))
+			if tt.header != "" {
+				req.Header.Set("X-Peer-Token", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			peer.Handler().ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Errorf("got status %d, want %d", rec.Code, tt.status)
+			}
+		})
+	}
+}
+
+func TestPeerReservationIDs(t *testing.T) {
+	peer := NewPeerRateLimiter(PeerConfig{Self: "http://self", Token: "secret"})
+	handler := peer.Handler()
+	post := func(path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/internal/ratelimit"+path, strings.NewReader(body))
+		req.Header.Set("X-Peer-Token", "secret")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	seen := make(map[string]bool)
+	for i := 0; i < 3; i++ {
+		var reply peerDecision
+		rec := post("/allow", `{"key":"rate_limit:test","limit":10,"window_ms":60000}`)
+		if err := json.NewDecoder(rec.Body).Decode(&reply); err != nil {
+			t.Fatal(err)
+		}
+		if len(reply.Reservation) < 26 || seen[reply.Reservation] {
+			t.Fatalf("got reservation ID %q, want a new random ID", reply.Reservation)
+		}
+		seen[reply.Reservation] = true
+	}
+
+	// Guessed IDs refund nothing
+	for _, guess := range []string{"1", "2", "3", "a"} {
+		post("/refund", `{"reservation":"`+guess+`"}`)
+	}
+	if got := remaining(t, peer, "rate_limit:test", 10); got != 7 {
+		t.Errorf("got remaining %d after guessed refunds, want 7", got)
+	}
+}
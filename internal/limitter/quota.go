@@ -0,0 +1,100 @@
+// internal/limitter/quota.go
+package limitter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// QuotaResult represents the state of an amount-based quota
+type QuotaResult struct {
+	Allowed   bool
+	Used      int64
+	Remaining int64
+	ResetTime time.Time
+	// Reservation undoes the consumed amount when refunded (nil for Usage)
+	Reservation Reservation
+}
+
+// QuotaLimiter limits an arbitrary amount (e.g. bytes) per key and window
+type QuotaLimiter interface {
+	Usage(ctx context.Context, key string, quota int64, window time.Duration) (*QuotaResult, error)
+	Consume(ctx context.Context, key string, amount, quota int64, window time.Duration) (*QuotaResult, error)
+}
+
+// RedisQuotaLimiter implements QuotaLimiter with fixed window counters in Redis
+type RedisQuotaLimiter struct {
+	client RedisClient
+}
+
+// NewRedisQuotaLimiter creates a new Redis-based quota limiter
+func NewRedisQuotaLimiter(client RedisClient) *RedisQuotaLimiter {
+	return &RedisQuotaLimiter{
+		client: client,
+	}
+}
+
+// Usage returns the current quota state without consuming anything
+func (q *RedisQuotaLimiter) Usage(ctx context.Context, key string, quota int64, window time.Duration) (*QuotaResult, error) {
+	windowKey, resetTime := quotaWindow(key, window, time.Now())
+
+	var used int64
+	val, err := q.client.Get(ctx, windowKey).Result()
+	if err != nil && !errors.Is(err, Nil) {
+		return nil, fmt.Errorf("failed to get quota usage: %w", err)
+	}
+	if val != "" {
+		if used, err = strconv.ParseInt(val, 10, 64); err != nil {
+			return nil, fmt.Errorf("invalid quota usage %q: %w", val, err)
+		}
+	}
+
+	return newQuotaResult(used, quota, used < quota, resetTime), nil
+}
+
+// Consume adds amount to the usage of key. The result is not allowed if the
+// quota was already exhausted before this amount was added.
+func (q *RedisQuotaLimiter) Consume(ctx context.Context, key string, amount, quota int64, window time.Duration) (*QuotaResult, error) {
+	windowKey, resetTime := quotaWindow(key, window, time.Now())
+
+	used, err := q.client.IncrBy(ctx, windowKey, amount).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to consume quota: %w", err)
+	}
+
+	// Set expiration for cleanup
+	q.client.Expire(ctx, windowKey, time.Until(resetTime)+time.Minute)
+
+	result := newQuotaResult(used, quota, used-amount < quota, resetTime)
+	result.Reservation = newReservation(func(ctx context.Context) error {
+		if err := q.client.IncrBy(ctx, windowKey, -amount).Err(); err != nil {
+			return fmt.Errorf("failed to refund quota: %w", err)
+		}
+		return nil
+	})
+	return result, nil
+}
+
+// quotaWindow returns the counter key and reset time of the current fixed window
+func quotaWindow(key string, window time.Duration, now time.Time) (string, time.Time) {
+	index := now.UnixNano() / int64(window)
+	resetTime := time.Unix(0, (index+1)*int64(window))
+	return fmt.Sprintf("%s:%d", key, index), resetTime
+}
+
+func newQuotaResult(used, quota int64, allowed bool, resetTime time.Time) *QuotaResult {
+	remaining := quota - used
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	return &QuotaResult{
+		Allowed:   allowed,
+		Used:      used,
+		Remaining: remaining,
+		ResetTime: resetTime,
+	}
+}
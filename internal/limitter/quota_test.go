@@ -0,0 +1,88 @@
+// internal/limitter/quota_test.go
+package limitter
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestQuotaConsume(t *testing.T) {
+	tests := []struct {
+		name      string
+		amounts   []int64
+		allowed   []bool
+		remaining []int64
+	}{
+		{
+			name:      "within quota",
+			amounts:   []int64{30, 40},
+			allowed:   []bool{true, true},
+			remaining: []int64{70, 30},
+		},
+		{
+			name:      "last amount may overshoot",
+			amounts:   []int64{90, 50, 1},
+			allowed:   []bool{true, true, false},
+			remaining: []int64{10, 0, 0},
+		},
+		{
+			name:      "exhausted exactly",
+			amounts:   []int64{100, 1},
+			allowed:   []bool{true, false},
+			remaining: []int64{0, 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, client := newTestRedis(t)
+			quota := NewRedisQuotaLimiter(client)
+			ctx := context.Background()
+
+			for i, amount := range tt.amounts {
+				result, err := quota.Consume(ctx, "rate_limit:bandwidth:test", amount, 100, time.Hour)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if result.Allowed != tt.allowed[i] || result.Remaining != tt.remaining[i] {
+					t.Errorf("consume %d: got allowed %v remaining %d, want %v %d",
+						i, result.Allowed, result.Remaining, tt.allowed[i], tt.remaining[i])
+				}
+			}
+		})
+	}
+}
+
+func TestQuotaUsage(t *testing.T) {
+	server, client := newTestRedis(t)
+	quota := NewRedisQuotaLimiter(client)
+	ctx := context.Background()
+
+	usage, err := quota.Usage(ctx, "rate_limit:bandwidth:test", 100, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !usage.Allowed || usage.Used != 0 || usage.Remaining != 100 {
+		t.Errorf("got %+v for an unused quota", usage)
+	}
+
+	if _, err := quota.Consume(ctx, "rate_limit:bandwidth:test", 100, 100, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	usage, err = quota.Usage(ctx, "rate_limit:bandwidth:test", 100, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage.Allowed || usage.Used != 100 || usage.Remaining != 0 {
+		t.Errorf("got %+v for an exhausted quota", usage)
+	}
+	if !usage.ResetTime.After(time.Now()) || usage.ResetTime.After(time.Now().Add(time.Hour)) {
+		t.Errorf("got reset time %v, want within the hour", usage.ResetTime)
+	}
+
+	// Usage doesn't consume anything
+	if got := len(server.Keys()); got != 1 {
+		t.Errorf("got %d keys, want the one window counter", got)
+	}
+}
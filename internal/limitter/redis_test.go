@@ -0,0 +1,273 @@
+// internal/limitter/redis_test.go
+package limitter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// newTestRedis starts an in-process Redis and returns a client for it
+func newTestRedis(t *testing.T) (*miniredis.Miniredis, RedisClient) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return server, &testClient{client: client}
+}
+
+// backend creates a fresh limiter of one backend under test
+type backend[T any] struct {
+	name string
+	new  func(t *testing.T) T
+}
+
+// forEachBackend runs fn as a subtest per backend, each with a fresh limiter
+func forEachBackend[T any](t *testing.T, backends []backend[T], fn func(t *testing.T, limiter T)) {
+	t.Helper()
+	for _, b := range backends {
+		t.Run(b.name, func(t *testing.T) {
+			fn(t, b.new(t))
+		})
+	}
+}
+
+// testCmd adapts a go-redis command to the command interfaces
+type testCmd[T any] struct {
+	cmd interface {
+		Result() (T, error)
+		Err() error
+		Val() T
+	}
+}
+
+func (c testCmd[T]) Result() (T, error) {
+	val, err := c.cmd.Result()
+	return val, testErr(err)
+}
+
+func (c testCmd[T]) Err() error { return testErr(c.cmd.Err()) }
+func (c testCmd[T]) Val() T     { return c.cmd.Val() }
+
+// testScanCmd adapts a go-redis SCAN command
+type testScanCmd struct{ cmd *redis.ScanCmd }
+
+func (c testScanCmd) Result() ([]string, uint64, error) {
+	keys, cursor, err := c.cmd.Result()
+	return keys, cursor, testErr(err)
+}
+
+func (c testScanCmd) Err() error { return testErr(c.cmd.Err()) }
+
+// testScoresCmd flattens a ZRANGE WITHSCORES reply like the server wrapper
+type testScoresCmd struct{ cmd *redis.ZSliceCmd }
+
+func (c testScoresCmd) Result() ([]string, error) {
+	return c.Val(), c.Err()
+}
+
+func (c testScoresCmd) Err() error { return testErr(c.cmd.Err()) }
+
+func (c testScoresCmd) Val() []string {
+	var flat []string
+	for _, z := range c.cmd.Val() {
+		flat = append(flat, fmt.Sprintf("%v", z.Member), fmt.Sprintf("%g", z.Score))
+	}
+	return flat
+}
+
+func testErr(err error) error {
+	if errors.Is(err, redis.Nil) {
+		return Nil
+	}
+	return err
+}
+
+func withScores(args []interface{}) bool {
+	for _, arg := range args {
+		if s, ok := arg.(string); ok && strings.EqualFold(s, "WITHSCORES") {
+			return true
+		}
+	}
+	return false
+}
+
+// testClient implements RedisClient on go-redis
+type testClient struct{ client *redis.Client }
+
+func (c *testClient) Get(ctx context.Context, key string) StringCmd {
+	return testCmd[string]{c.client.Get(ctx, key)}
+}
+
+func (c *testClient) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) StatusCmd {
+	return testCmd[string]{c.client.Set(ctx, key, value, expiration)}
+}
+
+func (c *testClient) SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) BoolCmd {
+	return testCmd[bool]{c.client.SetNX(ctx, key, value, expiration)}
+}
+
+func (c *testClient) Incr(ctx context.Context, key string) IntCmd {
+	return testCmd[int64]{c.client.Incr(ctx, key)}
+}
+
+func (c *testClient) IncrBy(ctx context.Context, key string, value int64) IntCmd {
+	return testCmd[int64]{c.client.IncrBy(ctx, key, value)}
+}
+
+func (c *testClient) Expire(ctx context.Context, key string, expiration time.Duration) BoolCmd {
+	return testCmd[bool]{c.client.Expire(ctx, key, expiration)}
+}
+
+func (c *testClient) Del(ctx context.Context, keys ...string) IntCmd {
+	return testCmd[int64]{c.client.Del(ctx, keys...)}
+}
+
+func (c *testClient) Close() error { return c.client.Close() }
+
+func (c *testClient) TTL(ctx context.Context, key string) DurationCmd {
+	return testCmd[time.Duration]{c.client.TTL(ctx, key)}
+}
+
+func (c *testClient) Ping(ctx context.Context) StatusCmd {
+	return testCmd[string]{c.client.Ping(ctx)}
+}
+
+func (c *testClient) HealthCheck(ctx context.Context) error {
+	return testErr(c.client.Ping(ctx).Err())
+}
+
+func (c *testClient) Pipeline() Pipeline {
+	return &testPipeline{pipe: c.client.Pipeline()}
+}
+
+func (c *testClient) ZRemRangeByScore(ctx context.Context, key string, min, max string) IntCmd {
+	return testCmd[int64]{c.client.ZRemRangeByScore(ctx, key, min, max)}
+}
+
+func (c *testClient) ZRemRangeByRank(ctx context.Context, key string, start, stop int64) IntCmd {
+	return testCmd[int64]{c.client.ZRemRangeByRank(ctx, key, start, stop)}
+}
+
+func (c *testClient) ZRem(ctx context.Context, key string, members ...interface{}) IntCmd {
+	return testCmd[int64]{c.client.ZRem(ctx, key, members...)}
+}
+
+func (c *testClient) ZCard(ctx context.Context, key string) IntCmd {
+	return testCmd[int64]{c.client.ZCard(ctx, key)}
+}
+
+func (c *testClient) ZRange(ctx context.Context, key string, start, stop int64, args ...interface{}) StringSliceCmd {
+	if withScores(args) {
+		return testScoresCmd{c.client.ZRangeWithScores(ctx, key, start, stop)}
+	}
+	return testCmd[[]string]{c.client.ZRange(ctx, key, start, stop)}
+}
+
+func (c *testClient) ZRevRange(ctx context.Context, key string, start, stop int64, args ...interface{}) StringSliceCmd {
+	if withScores(args) {
+		return testScoresCmd{c.client.ZRevRangeWithScores(ctx, key, start, stop)}
+	}
+	return testCmd[[]string]{c.client.ZRevRange(ctx, key, start, stop)}
+}
+
+func (c *testClient) ZAdd(ctx context.Context, key string, score float64, member interface{}) IntCmd {
+	return testCmd[int64]{c.client.ZAdd(ctx, key, redis.Z{Score: score, Member: member})}
+}
+
+func (c *testClient) ZIncrBy(ctx context.Context, key string, increment float64, member string) FloatCmd {
+	return testCmd[float64]{c.client.ZIncrBy(ctx, key, increment, member)}
+}
+
+func (c *testClient) ZCount(ctx context.Context, key string, min, max string) IntCmd {
+	return testCmd[int64]{c.client.ZCount(ctx, key, min, max)}
+}
+
+func (c *testClient) PFAdd(ctx context.Context, key string, els ...interface{}) IntCmd {
+	return testCmd[int64]{c.client.PFAdd(ctx, key, els...)}
+}
+
+func (c *testClient) PFCount(ctx context.Context, keys ...string) IntCmd {
+	return testCmd[int64]{c.client.PFCount(ctx, keys...)}
+}
+
+func (c *testClient) PFMerge(ctx context.Context, dest string, keys ...string) StatusCmd {
+	return testCmd[string]{c.client.PFMerge(ctx, dest, keys...)}
+}
+
+func (c *testClient) Eval(ctx context.Context, script string, keys []string, args ...interface{}) InterfaceCmd {
+	return testCmd[interface{}]{c.client.Eval(ctx, script, keys, args...)}
+}
+
+func (c *testClient) HIncrBy(ctx context.Context, key, field string, incr int64) IntCmd {
+	return testCmd[int64]{c.client.HIncrBy(ctx, key, field, incr)}
+}
+
+func (c *testClient) HGetAll(ctx context.Context, key string) MapStringStringCmd {
+	return testCmd[map[string]string]{c.client.HGetAll(ctx, key)}
+}
+
+func (c *testClient) HSet(ctx context.Context, key string, values ...interface{}) IntCmd {
+	return testCmd[int64]{c.client.HSet(ctx, key, values...)}
+}
+
+func (c *testClient) Scan(ctx context.Context, cursor uint64, match string, count int64) ScanCmd {
+	return testScanCmd{c.client.Scan(ctx, cursor, match, count)}
+}
+
+func (c *testClient) Type(ctx context.Context, key string) StatusCmd {
+	return testCmd[string]{c.client.Type(ctx, key)}
+}
+
+// testPipeline implements Pipeline on a go-redis pipeline
+type testPipeline struct{ pipe redis.Pipeliner }
+
+func (p *testPipeline) Eval(ctx context.Context, script string, keys []string, args ...interface{}) InterfaceCmd {
+	return testCmd[interface{}]{p.pipe.Eval(ctx, script, keys, args...)}
+}
+
+func (p *testPipeline) ZRemRangeByScore(ctx context.Context, key string, min, max string) IntCmd {
+	return testCmd[int64]{p.pipe.ZRemRangeByScore(ctx, key, min, max)}
+}
+
+func (p *testPipeline) ZCard(ctx context.Context, key string) IntCmd {
+	return testCmd[int64]{p.pipe.ZCard(ctx, key)}
+}
+
+func (p *testPipeline) ZRange(ctx context.Context, key string, start, stop int64, args ...interface{}) StringSliceCmd {
+	if withScores(args) {
+		return testScoresCmd{p.pipe.ZRangeWithScores(ctx, key, start, stop)}
+	}
+	return testCmd[[]string]{p.pipe.ZRange(ctx, key, start, stop)}
+}
+
+func (p *testPipeline) ZAdd(ctx context.Context, key string, score float64, member interface{}) IntCmd {
+	return testCmd[int64]{p.pipe.ZAdd(ctx, key, redis.Z{Score: score, Member: member})}
+}
+
+func (p *testPipeline) ZIncrBy(ctx context.Context, key string, increment float64, member string) FloatCmd {
+	return testCmd[float64]{p.pipe.ZIncrBy(ctx, key, increment, member)}
+}
+
+func (p *testPipeline) IncrBy(ctx context.Context, key string, value int64) IntCmd {
+	return testCmd[int64]{p.pipe.IncrBy(ctx, key, value)}
+}
+
+func (p *testPipeline) Expire(ctx context.Context, key string, expiration time.Duration) BoolCmd {
+	return testCmd[bool]{p.pipe.Expire(ctx, key, expiration)}
+}
+
+func (p *testPipeline) Exec(ctx context.Context) ([]Cmd, error) {
+	cmds, err := p.pipe.Exec(ctx)
+	result := make([]Cmd, len(cmds))
+	for i, cmd := range cmds {
+		result[i] = cmd
+	}
+	return result, testErr(err)
+}
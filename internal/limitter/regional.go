@@ -0,0 +1,254 @@
+// internal/limitter/regional.go
+package limitter
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// regionalAllowScript adds ARGV[2] to the counter field ARGV[1] if the merged
+// total of the window stays within ARGV[3]. Fields ending in ":n" count
+// refunds and are subtracted. Returns {allowed, total}.
+const regionalAllowScript = `
+local fields = redis.call('HGETALL', KEYS[1])
+local total = 0
+for i = 1, #fields, 2 do
+	local v = tonumber(fields[i + 1])
+	if string.sub(fields[i], -2) == ':n' then
+		total = total - v
+	else
+		total = total + v
+	end
+end
+local n = tonumber(ARGV[2])
+if total + n > tonumber(ARGV[3]) then
+	return {0, total}
+end
+redis.call('HINCRBY', KEYS[1], ARGV[1], n)
+redis.call('PEXPIRE', KEYS[1], ARGV[4])
+return {1, total + n}
+`
+
+// regionalMergeScript merges field/value pairs from ARGV[2..] into a counter
+// hash, keeping the larger value of each field. ARGV[1] is the TTL in ms.
+const regionalMergeScript = `
+for i = 2, #ARGV, 2 do
+	local cur = tonumber(redis.call('HGET', KEYS[1], ARGV[i]) or '0')
+	if tonumber(ARGV[i + 1]) > cur then
+		redis.call('HSET', KEYS[1], ARGV[i], ARGV[i + 1])
+	end
+end
+redis.call('PEXPIRE', KEYS[1], ARGV[1])
+return 1
+`
+
+// RegionalConfig holds configuration for active-active replication
+type RegionalConfig struct {
+	// Name of the local region, used as its counter field (required)
+	Region string
+	// Redis clients of the other regions
+	Peers []RedisClient
+	// How often local changes are pushed to the peers (default 100ms)
+	ReplicationLag time.Duration
+	// How long one push to one peer may take (default 1s)
+	PeerTimeout time.Duration
+	// Called when a counter fails to replicate; it is retried later
+	// (optional). May be called concurrently for different peers.
+	OnReplicationError func(counterKey string, err error)
+}
+
+// RegionalRateLimiter keeps fixed-window PN-counters with one increment and
+// one refund field per region in every region's Redis. Each region only
+// writes its own fields locally and pushes them to its peers asynchronously,
+// where they are merged by taking the larger value. Decisions use the sum of
+// all fields, so they see other regions' traffic after the replication lag.
+type RegionalRateLimiter struct {
+	client RedisClient
+	config RegionalConfig
+
+	mu    sync.Mutex
+	dirty map[string]time.Duration
+}
+
+// NewRegionalRateLimiter creates a new multi-region rate limiter. Call Run to
+// start replication.
+func NewRegionalRateLimiter(client RedisClient, config RegionalConfig) *RegionalRateLimiter {
+	if config.ReplicationLag <= 0 {
+		config.ReplicationLag = 100 * time.Millisecond
+	}
+	if config.PeerTimeout <= 0 {
+		config.PeerTimeout = time.Second
+	}
+
+	return &RegionalRateLimiter{
+		client: client,
+		config: config,
+		dirty:  make(map[string]time.Duration),
+	}
+}
+
+// IsAllowed checks if a request is allowed based on the merged global count
+func (g *RegionalRateLimiter) IsAllowed(ctx context.Context, key string, limit int, window time.Duration) (*RateLimitResult, error) {
+	return g.IsAllowedN(ctx, key, limit, window, 1)
+}
+
+// IsAllowedN checks if a request costing n units is allowed based on the
+// merged global count. n must not be negative. Rejected requests, including
+// those costing more than limit, are not counted.
+func (g *RegionalRateLimiter) IsAllowedN(ctx context.Context, key string, limit int, window time.Duration, n int) (*RateLimitResult, error) {
+	if n < 0 {
+		return nil, ErrInvalidCost
+	}
+
+	now := time.Now()
+	index := now.UnixNano() / int64(window)
+	counterKey := fmt.Sprintf("%s:g:%d", key, index)
+	resetTime := time.Unix(0, (index+1)*int64(window))
+	ttl := window + time.Minute
+
+	val, err := g.client.Eval(ctx, regionalAllowScript, []string{counterKey}, g.config.Region, n, limit, ttl.Milliseconds()).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to check regional counter: %w", err)
+	}
+
+	reply, ok := val.([]interface{})
+	if !ok || len(reply) != 2 {
+		return nil, fmt.Errorf("unexpected regional reply: %v", val)
+	}
+	allowed, _ := reply[0].(int64)
+	total, _ := reply[1].(int64)
+
+	result := &RateLimitResult{
+		Allowed:   allowed == 1,
+		Remaining: max(limit-int(total), 0),
+		ResetTime: resetTime,
+	}
+	if !result.Allowed {
+		result.RetryAfter = time.Until(resetTime)
+		return result, nil
+	}
+
+	g.markDirty(counterKey, ttl)
+	result.Reservation = newReservation(func(ctx context.Context) error {
+		// Counters only grow, so refunds go to the region's refund field
+		if err := g.client.HIncrBy(ctx, counterKey, g.config.Region+":n", int64(n)).Err(); err != nil {
+			return fmt.Errorf("failed to refund regional counter: %w", err)
+		}
+		g.markDirty(counterKey, ttl)
+		return nil
+	})
+	return result, nil
+}
+
+// Adjust implements CostLimiter, charging delta more units to the current
+// window of key or refunding them when delta is negative
+func (g *RegionalRateLimiter) Adjust(ctx context.Context, key string, window time.Duration, delta int) error {
+	if delta == 0 {
+		return nil
+	}
+
+	index := time.Now().UnixNano() / int64(window)
+	counterKey := fmt.Sprintf("%s:g:%d", key, index)
+	ttl := window + time.Minute
+
+	// Counters only grow, so refunds go to the region's refund field
+	field, n := g.config.Region, int64(delta)
+	if delta < 0 {
+		field, n = g.config.Region+":n", int64(-delta)
+	}
+	if err := g.client.HIncrBy(ctx, counterKey, field, n).Err(); err != nil {
+		return fmt.Errorf("failed to adjust regional counter: %w", err)
+	}
+	g.client.Expire(ctx, counterKey, ttl)
+	g.markDirty(counterKey, ttl)
+	return nil
+}
+
+// Run pushes local changes to the peers every ReplicationLag until ctx is
+// cancelled, then pushes once more
+func (g *RegionalRateLimiter) Run(ctx context.Context) {
+	ticker := time.NewTicker(g.config.ReplicationLag)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			g.Replicate(flushCtx)
+			cancel()
+			return
+		case <-ticker.C:
+			g.Replicate(ctx)
+		}
+	}
+}
+
+// Replicate pushes the local region's fields of every changed counter to the
+// peers, each peer in parallel and within PeerTimeout. Counters that fail to
+// replicate are retried on the next call.
+func (g *RegionalRateLimiter) Replicate(ctx context.Context) {
+	g.mu.Lock()
+	dirty := g.dirty
+	g.dirty = make(map[string]time.Duration)
+	g.mu.Unlock()
+
+	updates := make(map[string][]interface{}, len(dirty))
+	for counterKey, ttl := range dirty {
+		fields, err := g.client.HGetAll(ctx, counterKey).Result()
+		if err != nil {
+			g.replicationFailed(counterKey, ttl, err)
+			continue
+		}
+
+		args := []interface{}{ttl.Milliseconds()}
+		for field, value := range fields {
+			if strings.TrimSuffix(field, ":n") != g.config.Region {
+				continue
+			}
+			args = append(args, field, value)
+		}
+		if len(args) == 1 {
+			continue
+		}
+		updates[counterKey] = args
+	}
+	if len(updates) == 0 {
+		return
+	}
+
+	// A slow or unreachable peer must not hold back the others
+	var wg sync.WaitGroup
+	for _, peer := range g.config.Peers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			peerCtx, cancel := context.WithTimeout(ctx, g.config.PeerTimeout)
+			defer cancel()
+
+			for counterKey, args := range updates {
+				if err := peer.Eval(peerCtx, regionalMergeScript, []string{counterKey}, args...).Err(); err != nil {
+					g.replicationFailed(counterKey, dirty[counterKey], err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// markDirty records that counterKey has local changes to replicate
+func (g *RegionalRateLimiter) markDirty(counterKey string, ttl time.Duration) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.dirty[counterKey] = ttl
+}
+
+// replicationFailed reports err and keeps counterKey for the next round
+func (g *RegionalRateLimiter) replicationFailed(counterKey string, ttl time.Duration, err error) {
+	g.markDirty(counterKey, ttl)
+	if g.config.OnReplicationError != nil {
+		g.config.OnReplicationError(counterKey, err)
+	}
+}
@@ -0,0 +1,209 @@
+// internal/limitter/regional_test.go
+package limitter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func TestRegionalMergeKeepsLargerValues(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing map[string]string
+		incoming []interface{}
+		want     map[string]string
+	}{
+		{
+			name:     "new field is added",
+			existing: map[string]string{"eu": "3"},
+			incoming: []interface{}{"us", "2"},
+			want:     map[string]string{"eu": "3", "us": "2"},
+		},
+		{
+			name:     "larger value replaces",
+			existing: map[string]string{"us": "2"},
+			incoming: []interface{}{"us", "5"},
+			want:     map[string]string{"us": "5"},
+		},
+		{
+			name:     "stale value is ignored",
+			existing: map[string]string{"us": "5"},
+			incoming: []interface{}{"us", "2"},
+			want:     map[string]string{"us": "5"},
+		},
+		{
+			name:     "refund fields merge like increments",
+			existing: map[string]string{"us": "5", "us:n": "1"},
+			incoming: []interface{}{"us", "5", "us:n", "3"},
+			want:     map[string]string{"us": "5", "us:n": "3"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, client := newTestRedis(t)
+			ctx := context.Background()
+			for field, value := range tt.existing {
+				server.HSet("counter", field, value)
+			}
+
+			args := append([]interface{}{60000}, tt.incoming...)
+			if err := client.Eval(ctx, regionalMergeScript, []string{"counter"}, args...).Err(); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := client.HGetAll(ctx, "counter").Result()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got fields %v, want %v", got, tt.want)
+			}
+			for field, value := range tt.want {
+				if got[field] != value {
+					t.Errorf("field %s: got %q, want %q", field, got[field], value)
+				}
+			}
+		})
+	}
+}
+
+func TestRegionalCost(t *testing.T) {
+	tests := []struct {
+		name      string
+		costs     []int
+		allowed   []bool
+		remaining []int
+	}{
+		{
+			name:      "rejected requests are not counted",
+			costs:     []int{4, 4, 4, 2},
+			allowed:   []bool{true, true, false, true},
+			remaining: []int{6, 2, 2, 0},
+		},
+		{
+			name:      "cost above the limit is rejected",
+			costs:     []int{1000000, 1},
+			allowed:   []bool{false, true},
+			remaining: []int{10, 9},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, client := newTestRedis(t)
+			regional := NewRegionalRateLimiter(client, RegionalConfig{Region: "eu"})
+			ctx := context.Background()
+
+			for i, cost := range tt.costs {
+				result, err := regional.IsAllowedN(ctx, "rate_limit:test", 10, time.Hour, cost)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if result.Allowed != tt.allowed[i] || result.Remaining != tt.remaining[i] {
+					t.Errorf("request %d costing %d: got allowed=%v remaining=%d, want allowed=%v remaining=%d",
+						i, cost, result.Allowed, result.Remaining, tt.allowed[i], tt.remaining[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRegionalReplication(t *testing.T) {
+	tests := []struct {
+		name      string
+		eu        []int
+		us        []int
+		refundEU  bool
+		remaining int
+	}{
+		{name: "both regions count", eu: []int{3}, us: []int{2}, remaining: 5},
+		{name: "refunds replicate", eu: []int{3}, us: []int{2}, refundEU: true, remaining: 8},
+		{name: "one region only", eu: []int{1, 1}, remaining: 8},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, euClient := newTestRedis(t)
+			_, usClient := newTestRedis(t)
+			eu := NewRegionalRateLimiter(euClient, RegionalConfig{Region: "eu", Peers: []RedisClient{usClient}})
+			us := NewRegionalRateLimiter(usClient, RegionalConfig{Region: "us", Peers: []RedisClient{euClient}})
+			ctx := context.Background()
+
+			for _, cost := range tt.eu {
+				result, err := eu.IsAllowedN(ctx, "rate_limit:test", 10, time.Minute, cost)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if tt.refundEU {
+					if err := result.Reservation.Refund(ctx); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+			for _, cost := range tt.us {
+				if _, err := us.IsAllowedN(ctx, "rate_limit:test", 10, time.Minute, cost); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// Replicating twice must not count anything twice
+			for i := 0; i < 2; i++ {
+				eu.Replicate(ctx)
+				us.Replicate(ctx)
+			}
+
+			for name, limiter := range map[string]*RegionalRateLimiter{"eu": eu, "us": us} {
+				if got := remaining(t, limiter, "rate_limit:test", 10); got != tt.remaining {
+					t.Errorf("%s: got remaining %d, want %d", name, got, tt.remaining)
+				}
+			}
+		})
+	}
+}
+
+// stalledPeer is a peer region whose Redis never answers
+type stalledPeer struct {
+	RedisClient
+}
+
+func (p stalledPeer) Eval(ctx context.Context, script string, keys []string, args ...interface{}) InterfaceCmd {
+	<-ctx.Done()
+	cmd := redis.NewCmd(ctx)
+	cmd.SetErr(ctx.Err())
+	return testCmd[interface{}]{cmd: cmd}
+}
+
+func TestRegionalReplicationSkipsStalledPeer(t *testing.T) {
+	_, euClient := newTestRedis(t)
+	_, usClient := newTestRedis(t)
+	var failed []string
+	eu := NewRegionalRateLimiter(euClient, RegionalConfig{
+		Region:             "eu",
+		Peers:              []RedisClient{stalledPeer{euClient}, usClient},
+		PeerTimeout:        50 * time.Millisecond,
+		OnReplicationError: func(counterKey string, err error) { failed = append(failed, counterKey) },
+	})
+	us := NewRegionalRateLimiter(usClient, RegionalConfig{Region: "us"})
+	ctx := context.Background()
+
+	if _, err := eu.IsAllowedN(ctx, "rate_limit:test", 10, time.Minute, 4); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	eu.Replicate(ctx)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("replication took %v with a stalled peer", elapsed)
+	}
+
+	if got := remaining(t, us, "rate_limit:test", 10); got != 6 {
+		t.Errorf("got remaining %d in the healthy region, want 6", got)
+	}
+	if len(failed) != 1 {
+		t.Errorf("got replication errors for %v, want one", failed)
+	}
+}
@@ -0,0 +1,56 @@
+// internal/limitter/reservation.go
+package limitter
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrNotRefundable is returned when a reservation's charge cannot be undone
+var ErrNotRefundable = errors.New("limitter: reservation cannot be refunded")
+
+// Reservation is the charge recorded by a rate limit decision. It can be
+// committed once the request succeeded or refunded when it failed on our side.
+// Only the first call to Commit or Refund has an effect.
+type Reservation interface {
+	Commit(ctx context.Context) error
+	Refund(ctx context.Context) error
+}
+
+// reservation is a Reservation backed by a refund function
+type reservation struct {
+	mu     sync.Mutex
+	done   bool
+	refund func(ctx context.Context) error
+}
+
+// newReservation creates a reservation that calls refund when refunded
+func newReservation(refund func(ctx context.Context) error) *reservation {
+	return &reservation{refund: refund}
+}
+
+// Commit keeps the charge
+func (r *reservation) Commit(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.done = true
+	return nil
+}
+
+// Refund undoes the charge unless the reservation was already settled
+func (r *reservation) Refund(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.done {
+		return nil
+	}
+	r.done = true
+
+	if r.refund == nil {
+		return ErrNotRefundable
+	}
+	return r.refund(ctx)
+}
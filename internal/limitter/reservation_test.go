@@ -0,0 +1,193 @@
+// internal/limitter/reservation_test.go
+package limitter
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// costLimiters are every limiter whose charges can be refunded and adjusted
+var costLimiters = []backend[CostLimiter]{
+	{"sliding_log", func(t *testing.T) CostLimiter {
+		_, client := newTestRedis(t)
+		return NewRedisRateLimiter(client, &Config{})
+	}},
+	{"gcra", func(t *testing.T) CostLimiter {
+		_, client := newTestRedis(t)
+		return NewGCRARateLimiter(client)
+	}},
+	{"token_bucket", func(t *testing.T) CostLimiter {
+		_, client := newTestRedis(t)
+		return NewBucketRateLimiter(client)
+	}},
+	{"migrating", func(t *testing.T) CostLimiter {
+		_, client := newTestRedis(t)
+		return NewMigratingRateLimiter(client, NewGCRARateLimiter(client))
+	}},
+	{"regional", func(t *testing.T) CostLimiter {
+		_, client := newTestRedis(t)
+		return NewRegionalRateLimiter(client, RegionalConfig{Region: "eu"})
+	}},
+	{"memory", func(t *testing.T) CostLimiter {
+		return NewMemoryRateLimiter()
+	}},
+	{"peer", func(t *testing.T) CostLimiter {
+		return NewPeerRateLimiter(PeerConfig{Self: "http://self"})
+	}},
+}
+
+// remaining returns what is left of limit for key without charging it
+func remaining(t *testing.T, limiter CostLimiter, key string, limit int) int {
+	t.Helper()
+	result, err := limiter.IsAllowedN(context.Background(), key, limit, time.Minute, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result.Remaining
+}
+
+func TestReservationRefund(t *testing.T) {
+	tests := []struct {
+		name      string
+		costs     []int
+		refund    []bool
+		remaining int
+	}{
+		{name: "refund the only charge", costs: []int{3}, refund: []bool{true}, remaining: 10},
+		{name: "commit keeps the charge", costs: []int{3}, refund: []bool{false}, remaining: 7},
+		{name: "refund one of two charges", costs: []int{3, 4}, refund: []bool{false, true}, remaining: 7},
+		{name: "refund both charges", costs: []int{3, 4}, refund: []bool{true, true}, remaining: 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forEachBackend(t, costLimiters, func(t *testing.T, limiter CostLimiter) {
+				ctx := context.Background()
+				var reservations []Reservation
+				for _, cost := range tt.costs {
+					result, err := limiter.IsAllowedN(ctx, "rate_limit:test", 10, time.Minute, cost)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if !result.Allowed || result.Reservation == nil {
+						t.Fatalf("got allowed=%v reservation=%v, want an allowed reservation", result.Allowed, result.Reservation)
+					}
+					reservations = append(reservations, result.Reservation)
+				}
+
+				for i, reservation := range reservations {
+					settle := reservation.Commit
+					if tt.refund[i] {
+						settle = reservation.Refund
+					}
+					if err := settle(ctx); err != nil {
+						t.Fatalf("settling reservation %d: %v", i, err)
+					}
+					// Only the first settlement counts
+					if err := reservation.Refund(ctx); err != nil {
+						t.Fatalf("refunding settled reservation %d: %v", i, err)
+					}
+				}
+
+				if got := remaining(t, limiter, "rate_limit:test", 10); got != tt.remaining {
+					t.Errorf("got remaining %d, want %d", got, tt.remaining)
+				}
+			})
+		})
+	}
+}
+
+func TestAdjust(t *testing.T) {
+	tests := []struct {
+		name      string
+		cost      int
+		deltas    []int
+		remaining int
+	}{
+		{name: "charge more", cost: 2, deltas: []int{3}, remaining: 5},
+		{name: "refund part", cost: 4, deltas: []int{-3}, remaining: 9},
+		{name: "charge then refund", cost: 2, deltas: []int{2, -3}, remaining: 9},
+		{name: "no change", cost: 2, deltas: []int{0}, remaining: 8},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forEachBackend(t, costLimiters, func(t *testing.T, limiter CostLimiter) {
+				ctx := context.Background()
+				if _, err := limiter.IsAllowedN(ctx, "rate_limit:test", 10, time.Minute, tt.cost); err != nil {
+					t.Fatal(err)
+				}
+				for _, delta := range tt.deltas {
+					if err := limiter.Adjust(ctx, "rate_limit:test", time.Minute, delta); err != nil {
+						t.Fatal(err)
+					}
+				}
+
+				if got := remaining(t, limiter, "rate_limit:test", 10); got != tt.remaining {
+					t.Errorf("got remaining %d, want %d", got, tt.remaining)
+				}
+			})
+		})
+	}
+}
+
+func TestDistinctReservationRefund(t *testing.T) {
+	tests := []struct {
+		name    string
+		values  []string
+		refund  []bool
+		allowed []bool
+	}{
+		{
+			name:    "refunded value frees its slot",
+			values:  []string{"a", "b", "c"},
+			refund:  []bool{false, true, false},
+			allowed: []bool{true, true, true},
+		},
+		{
+			name:    "committed values fill the limit",
+			values:  []string{"a", "b", "c"},
+			refund:  []bool{false, false, false},
+			allowed: []bool{true, true, false},
+		},
+		{
+			name:    "refunded value is charged again when reused",
+			values:  []string{"a", "b", "b", "c"},
+			refund:  []bool{false, true, false, false},
+			allowed: []bool{true, true, true, false},
+		},
+		{
+			name:    "known values pass at the limit",
+			values:  []string{"a", "b", "a"},
+			refund:  []bool{false, false, false},
+			allowed: []bool{true, true, true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, client := newTestRedis(t)
+			limiter := NewRedisDistinctLimiter(client)
+			ctx := context.Background()
+
+			for i, value := range tt.values {
+				result, err := limiter.IsAllowedDistinct(ctx, "rate_limit:distinct:test", value, 2, time.Minute)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if result.Allowed != tt.allowed[i] {
+					t.Fatalf("value %d (%s): got allowed=%v, want %v", i, value, result.Allowed, tt.allowed[i])
+				}
+				if tt.refund[i] {
+					if result.Reservation == nil {
+						t.Fatalf("value %d (%s): no reservation to refund", i, value)
+					}
+					if err := result.Reservation.Refund(ctx); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,219 @@
+// internal/limitter/shaping.go
+package limitter
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// shapingChunkSize is the largest number of bytes moved per throttled read or write
+const shapingChunkSize = 32 * 1024
+
+// ByteLimiter throttles a stream of bytes
+type ByteLimiter interface {
+	// WaitN blocks until n bytes may be transferred or ctx is done
+	WaitN(ctx context.Context, n int) error
+}
+
+// TokenBucket is an in-process byte rate limiter
+type TokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// NewTokenBucket creates a token bucket refilled at bytesPerSecond and holding
+// at most burst bytes
+func NewTokenBucket(bytesPerSecond, burst int64) *TokenBucket {
+	if burst <= 0 {
+		burst = bytesPerSecond
+	}
+
+	return &TokenBucket{
+		rate:   float64(bytesPerSecond),
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// WaitN reserves n bytes and sleeps until the bucket has refilled enough to cover them
+func (b *TokenBucket) WaitN(ctx context.Context, n int) error {
+	b.mu.Lock()
+	now := time.Now()
+
+	// Refill tokens for the elapsed time
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+
+	// Take the tokens up front, a negative balance is paid off by waiting
+	b.tokens -= float64(n)
+	wait := time.Duration(0)
+	if b.tokens < 0 {
+		wait = time.Duration(-b.tokens / b.rate * float64(time.Second))
+	}
+	b.mu.Unlock()
+
+	return sleepContext(ctx, wait)
+}
+
+// byteBucketScript takes ARGV[4] bytes from the token bucket at KEYS[1],
+// refilled at ARGV[2] bytes per second up to ARGV[3] bytes. The balance may
+// go negative and the reply is the wait in microseconds until it is paid off.
+const byteBucketScript = `
+local now = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local burst = tonumber(ARGV[3])
+local n = tonumber(ARGV[4])
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1]) or burst
+local ts = tonumber(state[2]) or now
+tokens = math.min(burst, tokens + math.max(now - ts, 0) * rate / 1000000) - n
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', string.format('%d', now))
+redis.call('PEXPIRE', KEYS[1], math.ceil((burst - tokens) * 1000 / rate) + 1000)
+if tokens >= 0 then
+	return 0
+end
+return math.ceil(-tokens * 1000000 / rate)
+`
+
+// RedisByteLimiter coordinates a byte rate across instances using a token
+// bucket in Redis. While Redis is unavailable each instance shapes with its
+// own token bucket.
+type RedisByteLimiter struct {
+	client   RedisClient
+	key      string
+	rate     int64
+	burst    int64
+	fallback *TokenBucket
+}
+
+// NewRedisByteLimiter creates a byte limiter shared by every instance using
+// the same key, refilled at bytesPerSecond and holding at most burst bytes
+func NewRedisByteLimiter(client RedisClient, key string, bytesPerSecond, burst int64) *RedisByteLimiter {
+	if burst <= 0 {
+		burst = bytesPerSecond
+	}
+
+	return &RedisByteLimiter{
+		client:   client,
+		key:      key,
+		rate:     bytesPerSecond,
+		burst:    burst,
+		fallback: NewTokenBucket(bytesPerSecond, burst),
+	}
+}
+
+// WaitN takes n bytes from the shared bucket and sleeps until they are paid off
+func (l *RedisByteLimiter) WaitN(ctx context.Context, n int) error {
+	wait, err := l.client.Eval(ctx, byteBucketScript, []string{l.key}, time.Now().UnixMicro(), l.rate, l.burst, n).Result()
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return l.fallback.WaitN(ctx, n)
+	}
+
+	micros, ok := wait.(int64)
+	if !ok {
+		return fmt.Errorf("unexpected byte bucket reply: %v", wait)
+	}
+	return sleepContext(ctx, time.Duration(micros)*time.Microsecond)
+}
+
+// sleepContext sleeps for d or until ctx is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Reader is an io.Reader throttled by a ByteLimiter
+type Reader struct {
+	ctx     context.Context
+	r       io.Reader
+	limiter ByteLimiter
+}
+
+// NewReader wraps r so that reads are throttled by limiter
+func NewReader(ctx context.Context, r io.Reader, limiter ByteLimiter) *Reader {
+	return &Reader{
+		ctx:     ctx,
+		r:       r,
+		limiter: limiter,
+	}
+}
+
+// Read reads at most one chunk and waits until the bytes read are allowed
+func (r *Reader) Read(p []byte) (int, error) {
+	if len(p) > shapingChunkSize {
+		p = p[:shapingChunkSize]
+	}
+
+	n, err := r.r.Read(p)
+	if n > 0 {
+		if waitErr := r.limiter.WaitN(r.ctx, n); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
+
+// Writer is an io.Writer throttled by a ByteLimiter
+type Writer struct {
+	ctx     context.Context
+	w       io.Writer
+	limiter ByteLimiter
+}
+
+// NewWriter wraps w so that writes are throttled by limiter
+func NewWriter(ctx context.Context, w io.Writer, limiter ByteLimiter) *Writer {
+	return &Writer{
+		ctx:     ctx,
+		w:       w,
+		limiter: limiter,
+	}
+}
+
+// Write writes p in chunks, waiting before each chunk until it is allowed
+func (w *Writer) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		chunk := p
+		if len(chunk) > shapingChunkSize {
+			chunk = chunk[:shapingChunkSize]
+		}
+
+		if err := w.limiter.WaitN(w.ctx, len(chunk)); err != nil {
+			return written, err
+		}
+
+		n, err := w.w.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+	}
+	return written, nil
+}
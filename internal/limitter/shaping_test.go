@@ -0,0 +1,74 @@
+// internal/limitter/shaping_test.go
+package limitter
+
+import (
+	"context"
+	"testing"
+)
+
+func TestByteBucketScript(t *testing.T) {
+	tests := []struct {
+		name  string
+		burst int64
+		takes []int64
+		at    []int64
+		waits []int64
+	}{
+		{
+			name:  "burst is sent at once",
+			burst: 4000,
+			takes: []int64{4000, 1000},
+			at:    []int64{0, 0},
+			waits: []int64{0, 1000000},
+		},
+		{
+			name:  "burst defaults to the rate",
+			burst: 1000,
+			takes: []int64{500, 1000},
+			at:    []int64{0, 0},
+			waits: []int64{0, 500000},
+		},
+		{
+			name:  "debt is paid off by refills",
+			burst: 1000,
+			takes: []int64{2000, 500},
+			at:    []int64{0, 1500000},
+			waits: []int64{1000000, 0},
+		},
+		{
+			name:  "refills stop at the burst",
+			burst: 1000,
+			takes: []int64{1000, 2000},
+			at:    []int64{0, 10000000},
+			waits: []int64{0, 1000000},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, client := newTestRedis(t)
+			for i, n := range tt.takes {
+				wait, err := client.Eval(context.Background(), byteBucketScript, []string{"rate_limit:shape:test"}, tt.at[i], 1000, tt.burst, n).Result()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if wait != tt.waits[i] {
+					t.Errorf("take %d of %d bytes: got wait %vµs, want %dµs", i, n, wait, tt.waits[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRedisByteLimiterFallsBackWithoutRedis(t *testing.T) {
+	server, client := newTestRedis(t)
+	limiter := NewRedisByteLimiter(client, "rate_limit:shape:test", 1000, 0)
+	server.Close()
+
+	if err := limiter.WaitN(context.Background(), 1000); err != nil {
+		t.Fatalf("got %v, want the local bucket to allow the burst", err)
+	}
+	if tokens := limiter.fallback.tokens; tokens != 0 {
+		t.Errorf("got %v tokens left in the local bucket, want 0", tokens)
+	}
+}
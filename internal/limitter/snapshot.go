@@ -0,0 +1,174 @@
+// internal/limitter/snapshot.go
+package limitter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// snapshotVersion is the current version of the snapshot format. Version 1
+// snapshots have no costs and are restored with a cost of one per entry.
+const snapshotVersion = 2
+
+// memorySnapshot is the on-disk format of a MemoryRateLimiter snapshot
+type memorySnapshot struct {
+	Version int                    `json:"version"`
+	TakenAt time.Time              `json:"taken_at"`
+	Keys    map[string]snapshotLog `json:"keys"`
+}
+
+// snapshotLog is the request log of one key in a snapshot
+type snapshotLog struct {
+	WindowMs int64     `json:"window_ms"`
+	Expires  time.Time `json:"expires"`
+	// Entries are request timestamps in Unix nanoseconds, oldest first
+	Entries []int64 `json:"entries"`
+	// Costs are the costs of Entries
+	Costs []int `json:"costs"`
+}
+
+// Snapshot writes the state of every key to w
+func (m *MemoryRateLimiter) Snapshot(w io.Writer) error {
+	m.mu.Lock()
+	snapshot := memorySnapshot{
+		Version: snapshotVersion,
+		TakenAt: time.Now(),
+		Keys:    make(map[string]snapshotLog, len(m.logs)),
+	}
+	for key, log := range m.logs {
+		entries := make([]int64, len(log.entries))
+		costs := make([]int, len(log.entries))
+		for i, e := range log.entries {
+			entries[i] = e.at
+			costs[i] = e.n
+		}
+		snapshot.Keys[key] = snapshotLog{
+			WindowMs: log.window.Milliseconds(),
+			Expires:  log.expires,
+			Entries:  entries,
+			Costs:    costs,
+		}
+	}
+	m.mu.Unlock()
+
+	if err := json.NewEncoder(w).Encode(snapshot); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	return nil
+}
+
+// Restore loads a snapshot written by Snapshot, dropping entries that have
+// left their window since. Restored keys replace the current state of the
+// same keys. Returns the number of keys restored.
+func (m *MemoryRateLimiter) Restore(r io.Reader) (int, error) {
+	var snapshot memorySnapshot
+	if err := json.NewDecoder(r).Decode(&snapshot); err != nil {
+		return 0, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+	if snapshot.Version < 1 || snapshot.Version > snapshotVersion {
+		return 0, fmt.Errorf("unsupported snapshot version %d", snapshot.Version)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	restored := 0
+	for key, saved := range snapshot.Keys {
+		if !now.Before(saved.Expires) {
+			continue
+		}
+
+		window := time.Duration(saved.WindowMs) * time.Millisecond
+		log := &memoryLog{window: window, expires: saved.Expires}
+		for i, at := range saved.Entries {
+			n := 1
+			if i < len(saved.Costs) {
+				n = saved.Costs[i]
+			}
+			m.nextID++
+			log.entries = append(log.entries, memoryEntry{at: at, id: m.nextID, n: n})
+		}
+		log.trim(now.Add(-window).UnixNano())
+		if len(log.entries) == 0 {
+			continue
+		}
+
+		m.logs[key] = log
+		restored++
+	}
+	return restored, nil
+}
+
+// SaveSnapshot atomically replaces the snapshot file at path
+func (m *MemoryRateLimiter) SaveSnapshot(path string) error {
+	// Write next to the target so the rename stays on one filesystem
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create snapshot file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := m.Snapshot(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync snapshot: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close snapshot: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace snapshot: %w", err)
+	}
+	return nil
+}
+
+// LoadSnapshot restores the snapshot file at path. A missing file restores
+// nothing.
+func (m *MemoryRateLimiter) LoadSnapshot(path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to open snapshot: %w", err)
+	}
+	defer f.Close()
+
+	return m.Restore(f)
+}
+
+// RunSnapshots saves a snapshot to path every interval until ctx is
+// cancelled, then saves a final one. An interval of zero or less only saves
+// the final snapshot.
+func (m *MemoryRateLimiter) RunSnapshots(ctx context.Context, path string, interval time.Duration, onError func(error)) {
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			if err := m.SaveSnapshot(path); err != nil && onError != nil {
+				onError(err)
+			}
+			return
+		case <-tick:
+			if err := m.SaveSnapshot(path); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
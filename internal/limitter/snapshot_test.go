@@ -0,0 +1,161 @@
+// internal/limitter/snapshot_test.go
+package limitter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSnapshotRestore(t *testing.T) {
+	tests := []struct {
+		name      string
+		costs     []int
+		window    time.Duration
+		wait      time.Duration
+		restored  int
+		remaining int
+	}{
+		{name: "costs survive", costs: []int{3, 4}, window: time.Minute, restored: 1, remaining: 3},
+		{name: "single requests", costs: []int{1, 1, 1}, window: time.Minute, restored: 1, remaining: 7},
+		{name: "expired entries are dropped", costs: []int{5}, window: 20 * time.Millisecond, wait: 40 * time.Millisecond, restored: 0, remaining: 10},
+		{name: "empty state", window: time.Minute, restored: 0, remaining: 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			source := NewMemoryRateLimiter()
+			for _, cost := range tt.costs {
+				if _, err := source.IsAllowedN(ctx, "rate_limit:test", 10, tt.window, cost); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var buf bytes.Buffer
+			if err := source.Snapshot(&buf); err != nil {
+				t.Fatal(err)
+			}
+			time.Sleep(tt.wait)
+
+			target := NewMemoryRateLimiter()
+			restored, err := target.Restore(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if restored != tt.restored {
+				t.Errorf("got %d keys restored, want %d", restored, tt.restored)
+			}
+
+			result, err := target.Peek(ctx, "rate_limit:test", 10, tt.window)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Remaining != tt.remaining {
+				t.Errorf("got remaining %d, want %d", result.Remaining, tt.remaining)
+			}
+		})
+	}
+}
+
+func TestRestoreSnapshotVersions(t *testing.T) {
+	now := time.Now()
+	entries := []int64{now.UnixNano(), now.UnixNano()}
+
+	tests := []struct {
+		name      string
+		version   int
+		costs     []int
+		remaining int
+		wantErr   bool
+	}{
+		{name: "version 1 counts one per entry", version: 1, remaining: 8},
+		{name: "version 2 keeps costs", version: 2, costs: []int{2, 3}, remaining: 5},
+		{name: "unknown version", version: snapshotVersion + 1, wantErr: true},
+		{name: "missing version", version: 0, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.Marshal(memorySnapshot{
+				Version: tt.version,
+				TakenAt: now,
+				Keys: map[string]snapshotLog{
+					"rate_limit:test": {
+						WindowMs: time.Minute.Milliseconds(),
+						Expires:  now.Add(time.Minute),
+						Entries:  entries,
+						Costs:    tt.costs,
+					},
+				},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			limiter := NewMemoryRateLimiter()
+			_, err = limiter.Restore(bytes.NewReader(data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			result, err := limiter.Peek(context.Background(), "rate_limit:test", 10, time.Minute)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Remaining != tt.remaining {
+				t.Errorf("got remaining %d, want %d", result.Remaining, tt.remaining)
+			}
+		})
+	}
+}
+
+func TestRunSnapshots(t *testing.T) {
+	tests := []struct {
+		name     string
+		interval time.Duration
+	}{
+		{name: "periodic", interval: 5 * time.Millisecond},
+		{name: "shutdown only", interval: 0},
+		{name: "negative interval", interval: -time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "limits.json")
+			source := NewMemoryRateLimiter()
+			if _, err := source.IsAllowedN(context.Background(), "rate_limit:test", 10, time.Minute, 4); err != nil {
+				t.Fatal(err)
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan struct{})
+			var errs []string
+			go func() {
+				source.RunSnapshots(ctx, path, tt.interval, func(err error) { errs = append(errs, err.Error()) })
+				close(done)
+			}()
+			time.Sleep(20 * time.Millisecond)
+			cancel()
+			<-done
+
+			if len(errs) > 0 {
+				t.Fatalf("got snapshot errors: %s", strings.Join(errs, "; "))
+			}
+			restored, err := NewMemoryRateLimiter().LoadSnapshot(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if restored != 1 {
+				t.Errorf("got %d keys restored, want 1", restored)
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"rate-limiter/internal/limitter"
+)
+
+// BandwidthConfig holds configuration for byte quotas
+type BandwidthConfig struct {
+	// WindowSize is the quota period (e.g. 24 hours)
+	WindowSize time.Duration
+	// QuotaBytes is the number of bytes allowed per key in the window
+	QuotaBytes int64
+	// CountRequestBody also charges the bytes read from the request body
+	CountRequestBody bool
+	// KeyFunc extracts the key from the request (e.g., API key)
+	KeyFunc func(*http.Request) string
+	// SkipFunc determines if the quota should be skipped for this request
+	SkipFunc func(*http.Request) bool
+	// OnQuotaExceeded is called when the byte quota is exhausted
+	OnQuotaExceeded func(http.ResponseWriter, *http.Request, string)
+}
+
+// BandwidthMiddleware limits the number of body bytes served per key. The
+// quota is checked before the request and charged with the actual number of
+// bytes after the response, so the last response in a window may overshoot.
+func BandwidthMiddleware(limiter limitter.QuotaLimiter, config BandwidthConfig) func(http.Handler) http.Handler {
+	// Set default values
+	if config.WindowSize == 0 {
+		config.WindowSize = 24 * time.Hour
+	}
+	if config.QuotaBytes == 0 {
+		config.QuotaBytes = 1 << 30
+	}
+	if config.KeyFunc == nil {
+		config.KeyFunc = APIKeyFunc
+	}
+	if config.OnQuotaExceeded == nil {
+		config.OnQuotaExceeded = defaultOnQuotaExceeded
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if config.SkipFunc != nil && config.SkipFunc(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			key := config.KeyFunc(r)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			quotaKey := fmt.Sprintf("rate_limit:bandwidth:%s", key)
+
+			ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+			usage, err := limiter.Usage(ctx, quotaKey, config.QuotaBytes, config.WindowSize)
+			cancel()
+			if err != nil {
+				// Don't block the request if the quota can't be checked
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// Set bandwidth headers
+			w.Header().Set("X-Bandwidth-Limit", strconv.FormatInt(config.QuotaBytes, 10))
+			w.Header().Set("X-Bandwidth-Remaining", strconv.FormatInt(usage.Remaining, 10))
+			w.Header().Set("X-Bandwidth-Reset", strconv.FormatInt(usage.ResetTime.Unix(), 10))
+
+			if !usage.Allowed {
+				w.Header().Set("Retry-After", strconv.FormatInt(int64(time.Until(usage.ResetTime).Seconds()), 10))
+				config.OnQuotaExceeded(w, r, key)
+				return
+			}
+
+			var body *countingReader
+			if config.CountRequestBody && r.Body != nil {
+				body = &countingReader{ReadCloser: r.Body}
+				r.Body = body
+			}
+
+			rec := newResponseRecorder(w)
+			next.ServeHTTP(rec, r)
+
+			total := rec.BytesWritten()
+			if body != nil {
+				total += body.BytesRead()
+			}
+			if total == 0 {
+				return
+			}
+
+			ctx, cancel = context.WithTimeout(context.WithoutCancel(r.Context()), 5*time.Second)
+			defer cancel()
+			limiter.Consume(ctx, quotaKey, total, config.QuotaBytes, config.WindowSize)
+		})
+	}
+}
+
+// countingReader counts the bytes read from a request body
+type countingReader struct {
+	io.ReadCloser
+	bytes atomic.Int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.bytes.Add(int64(n))
+	return n, err
+}
+
+// BytesRead returns the number of bytes read so far
+func (c *countingReader) BytesRead() int64 {
+	return c.bytes.Load()
+}
+
+// defaultOnQuotaExceeded handles exhausted byte quotas
+func defaultOnQuotaExceeded(w http.ResponseWriter, r *http.Request, key string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusTooManyRequests)
+
+	response := fmt.Sprintf(`{
+		"error": "Bandwidth Quota Exceeded",
+		"message": "Transfer quota exhausted. Please try again after the quota resets.",
+		"code": %d,
+		"timestamp": "%s"
+	}`, http.StatusTooManyRequests, time.Now().UTC().Format(time.RFC3339))
+
+	w.Write([]byte(response))
+}
+
+// NewBandwidthQuota creates an API key-based byte quota
+func NewBandwidthQuota(limiter limitter.QuotaLimiter, quotaBytes int64, window time.Duration) func(http.Handler) http.Handler {
+	return BandwidthMiddleware(limiter, BandwidthConfig{
+		WindowSize: window,
+		QuotaBytes: quotaBytes,
+		KeyFunc:    APIKeyFunc,
+		SkipFunc:   SkipHealthChecks,
+	})
+}
@@ -0,0 +1,147 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"rate-limiter/internal/limitter"
+)
+
+// fakeQuota is an in-memory QuotaLimiter
+type fakeQuota struct {
+	mu   sync.Mutex
+	used map[string]int64
+	err  error
+}
+
+func newFakeQuota() *fakeQuota {
+	return &fakeQuota{used: make(map[string]int64)}
+}
+
+func (q *fakeQuota) Usage(ctx context.Context, key string, quota int64, window time.Duration) (*limitter.QuotaResult, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.err != nil {
+		return nil, q.err
+	}
+	used := q.used[key]
+	return &limitter.QuotaResult{
+		Allowed:   used < quota,
+		Used:      used,
+		Remaining: max(quota-used, 0),
+		ResetTime: time.Now().Add(window),
+	}, nil
+}
+
+func (q *fakeQuota) Consume(ctx context.Context, key string, amount, quota int64, window time.Duration) (*limitter.QuotaResult, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.err != nil {
+		return nil, q.err
+	}
+	q.used[key] += amount
+	used := q.used[key]
+	return &limitter.QuotaResult{
+		Allowed:   used-amount < quota,
+		Used:      used,
+		Remaining: max(quota-used, 0),
+		ResetTime: time.Now().Add(window),
+	}, nil
+}
+
+func TestBandwidthMiddleware(t *testing.T) {
+	tests := []struct {
+		name        string
+		countBody   bool
+		requestBody string
+		used        int64
+		err         error
+		status      int
+		charged     int64
+	}{
+		{
+			name:    "response bytes are charged",
+			status:  http.StatusOK,
+			charged: 5,
+		},
+		{
+			name:        "request body is charged when counted",
+			countBody:   true,
+			requestBody: "abc",
+			status:      http.StatusOK,
+			charged:     8,
+		},
+		{
+			name:        "request body is free by default",
+			requestBody: "abc",
+			status:      http.StatusOK,
+			charged:     5,
+		},
+		{
+			name:    "exhausted quota is rejected",
+			used:    10,
+			status:  http.StatusTooManyRequests,
+			charged: 10,
+		},
+		{
+			name:   "quota errors don't block",
+			err:    errors.New("unavailable"),
+			status: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			quota := newFakeQuota()
+			quota.used["rate_limit:bandwidth:client"] = tt.used
+			quota.err = tt.err
+
+			handler := BandwidthMiddleware(quota, BandwidthConfig{
+				QuotaBytes:       10,
+				CountRequestBody: tt.countBody,
+				KeyFunc:          func(*http.Request) string { return "client" },
+			})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				io.Copy(io.Discard, r.Body)
+				w.Write([]byte("hello"))
+			}))
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.requestBody)))
+
+			if rec.Code != tt.status {
+				t.Errorf("got status %d, want %d", rec.Code, tt.status)
+			}
+			quota.err = nil
+			if got := quota.used["rate_limit:bandwidth:client"]; got != tt.charged {
+				t.Errorf("got %d bytes charged, want %d", got, tt.charged)
+			}
+		})
+	}
+}
+
+func TestBandwidthHeaders(t *testing.T) {
+	quota := newFakeQuota()
+	quota.used["rate_limit:bandwidth:client"] = 4
+
+	handler := BandwidthMiddleware(quota, BandwidthConfig{
+		QuotaBytes: 10,
+		KeyFunc:    func(*http.Request) string { return "client" },
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if got := rec.Header().Get("X-Bandwidth-Limit"); got != "10" {
+		t.Errorf("got limit header %q, want 10", got)
+	}
+	if got := rec.Header().Get("X-Bandwidth-Remaining"); got != "6" {
+		t.Errorf("got remaining header %q, want 6", got)
+	}
+}
@@ -0,0 +1,211 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"rate-limiter/internal/limitter"
+)
+
+// BruteForceConfig holds configuration for login and OTP endpoint protection
+type BruteForceConfig struct {
+	// UsernameFunc extracts the attempted username from the request
+	UsernameFunc func(*http.Request) string
+	// IPFunc extracts the client IP address from the request. Defaults to
+	// TrustedProxyIPFunc(TrustedProxies).
+	IPFunc func(*http.Request) string
+	// TrustedProxies are the proxies whose X-Forwarded-For is believed
+	TrustedProxies []netip.Prefix
+	// VerifyChallenge reports whether the request carries a solved challenge,
+	// such as a CAPTCHA token. Without it, attempts for usernames needing a
+	// challenge are rejected until their failures expire.
+	VerifyChallenge func(*http.Request) bool
+	// OnLocked is called when the username is locked out
+	OnLocked func(http.ResponseWriter, *http.Request, *limitter.BanRecord)
+	// OnChallenge is called when an attempt needs a challenge it didn't solve
+	OnChallenge func(http.ResponseWriter, *http.Request)
+}
+
+// loginAttempt is stored in the request context so handlers can report the outcome
+type loginAttempt struct {
+	guard     limitter.LoginGuard
+	username  string
+	ip        string
+	challenge bool
+}
+
+type loginAttemptKey struct{}
+
+// ErrNoLoginAttempt is returned when a request did not pass through BruteForceMiddleware
+var ErrNoLoginAttempt = errors.New("request is not protected by brute-force middleware")
+
+// BruteForceMiddleware rejects attempts for locked out usernames. Only failures
+// reported through ReportLoginFailure are counted. Attempts for usernames
+// failing from many IPs get the X-Login-Challenge header and are rejected
+// unless they solved a challenge.
+func BruteForceMiddleware(guard limitter.LoginGuard, config BruteForceConfig) func(http.Handler) http.Handler {
+	if config.UsernameFunc == nil {
+		config.UsernameFunc = defaultUsernameFunc
+	}
+	if config.IPFunc == nil {
+		config.IPFunc = TrustedProxyIPFunc(config.TrustedProxies)
+	}
+	if config.VerifyChallenge == nil {
+		config.VerifyChallenge = func(*http.Request) bool { return false }
+	}
+	if config.OnLocked == nil {
+		config.OnLocked = defaultOnLocked
+	}
+	if config.OnChallenge == nil {
+		config.OnChallenge = defaultOnChallenge
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			username := config.UsernameFunc(r)
+			if username == "" {
+				// Nothing to protect without a username
+				next.ServeHTTP(w, r)
+				return
+			}
+			ip := config.IPFunc(r)
+
+			ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+			lockout, err := guard.Check(ctx, username, ip)
+			cancel()
+			if err == nil && lockout != nil {
+				w.Header().Set("Retry-After", strconv.FormatInt(int64(time.Until(lockout.ExpiresAt).Seconds()), 10))
+				config.OnLocked(w, r, lockout)
+				return
+			}
+
+			// Failing to read the challenge state lets the attempt through
+			// without one, like a failed lockout check
+			ctx, cancel = context.WithTimeout(r.Context(), 5*time.Second)
+			challenge, _ := guard.Challenge(ctx, username)
+			cancel()
+			if challenge {
+				w.Header().Set("X-Login-Challenge", "required")
+				if !config.VerifyChallenge(r) {
+					config.OnChallenge(w, r)
+					return
+				}
+			}
+
+			attempt := &loginAttempt{guard: guard, username: username, ip: ip, challenge: challenge}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), loginAttemptKey{}, attempt)))
+		})
+	}
+}
+
+// ReportLoginFailure records a failed attempt for the request and returns the
+// lockout it triggered, if any
+func ReportLoginFailure(r *http.Request) (*limitter.BanRecord, error) {
+	attempt, ok := r.Context().Value(loginAttemptKey{}).(*loginAttempt)
+	if !ok {
+		return nil, ErrNoLoginAttempt
+	}
+	return attempt.guard.RecordFailure(r.Context(), attempt.username, attempt.ip)
+}
+
+// ReportLoginSuccess resets the failure counters for the request's username
+func ReportLoginSuccess(r *http.Request) error {
+	attempt, ok := r.Context().Value(loginAttemptKey{}).(*loginAttempt)
+	if !ok {
+		return ErrNoLoginAttempt
+	}
+	return attempt.guard.RecordSuccess(r.Context(), attempt.username, attempt.ip)
+}
+
+// LoginChallengeRequired reports whether the request's username failed too
+// often across IPs, so the attempt only got through with a solved challenge
+func LoginChallengeRequired(r *http.Request) bool {
+	attempt, ok := r.Context().Value(loginAttemptKey{}).(*loginAttempt)
+	return ok && attempt.challenge
+}
+
+// TrustedProxyIPFunc returns an IPFunc reading the client IP behind the given
+// proxies. X-Forwarded-For is only believed when the peer is a trusted proxy,
+// and the client is the right-most address in it that isn't one, so clients
+// can't pick their IP by sending the header themselves.
+func TrustedProxyIPFunc(trusted []netip.Prefix) func(*http.Request) string {
+	isTrusted := func(addr netip.Addr) bool {
+		for _, prefix := range trusted {
+			if prefix.Contains(addr.Unmap()) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return func(r *http.Request) string {
+		peer, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			peer = r.RemoteAddr
+		}
+		addr, err := netip.ParseAddr(peer)
+		if err != nil || !isTrusted(addr) {
+			return peer
+		}
+
+		hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+			if err != nil {
+				// A trusted proxy forwarded garbage, so the chain ends here
+				return addr.String()
+			}
+			if !isTrusted(hop) {
+				return hop.Unmap().String()
+			}
+			addr = hop
+		}
+		return addr.String()
+	}
+}
+
+// defaultUsernameFunc reads the username from the form or the X-Username header
+func defaultUsernameFunc(r *http.Request) string {
+	if username := r.Header.Get("X-Username"); username != "" {
+		return username
+	}
+	return r.FormValue("username")
+}
+
+// defaultOnLocked handles attempts for locked out usernames
+func defaultOnLocked(w http.ResponseWriter, r *http.Request, lockout *limitter.BanRecord) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusTooManyRequests)
+
+	response := fmt.Sprintf(`{
+		"error": "Account Temporarily Locked",
+		"message": "Too many failed attempts. Please try again later.",
+		"code": %d,
+		"locked_until": "%s",
+		"timestamp": "%s"
+	}`, http.StatusTooManyRequests, lockout.ExpiresAt.UTC().Format(time.RFC3339), time.Now().UTC().Format(time.RFC3339))
+
+	w.Write([]byte(response))
+}
+
+// defaultOnChallenge handles attempts that need a challenge they didn't solve
+func defaultOnChallenge(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusTooManyRequests)
+
+	response := fmt.Sprintf(`{
+		"error": "Challenge Required",
+		"message": "Too many failed attempts for this account. Solve the challenge to continue.",
+		"code": %d,
+		"timestamp": "%s"
+	}`, http.StatusTooManyRequests, time.Now().UTC().Format(time.RFC3339))
+
+	w.Write([]byte(response))
+}
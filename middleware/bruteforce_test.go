@@ -0,0 +1,158 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"rate-limiter/internal/limitter"
+)
+
+// fakeGuard is an in-memory LoginGuard locking username+IP pairs and asking
+// for a challenge after failures across IPs
+type fakeGuard struct {
+	maxPerUserIP, maxPerUser int
+	userIP, user             map[string]int
+}
+
+func newFakeGuard(maxPerUserIP, maxPerUser int) *fakeGuard {
+	return &fakeGuard{
+		maxPerUserIP: maxPerUserIP,
+		maxPerUser:   maxPerUser,
+		userIP:       make(map[string]int),
+		user:         make(map[string]int),
+	}
+}
+
+func (g *fakeGuard) Check(ctx context.Context, username, ip string) (*limitter.BanRecord, error) {
+	if g.userIP[username+"|"+ip] >= g.maxPerUserIP {
+		return &limitter.BanRecord{Key: username, ExpiresAt: time.Now().Add(time.Minute)}, nil
+	}
+	return nil, nil
+}
+
+func (g *fakeGuard) Challenge(ctx context.Context, username string) (bool, error) {
+	return g.user[username] >= g.maxPerUser, nil
+}
+
+func (g *fakeGuard) RecordFailure(ctx context.Context, username, ip string) (*limitter.BanRecord, error) {
+	g.userIP[username+"|"+ip]++
+	g.user[username]++
+	return g.Check(ctx, username, ip)
+}
+
+func (g *fakeGuard) RecordSuccess(ctx context.Context, username, ip string) error {
+	delete(g.userIP, username+"|"+ip)
+	delete(g.user, username)
+	return nil
+}
+
+// loginHandler fails every attempt except with the password "secret"
+var loginHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("X-Password") == "secret" {
+		ReportLoginSuccess(r)
+		return
+	}
+	ReportLoginFailure(r)
+	w.WriteHeader(http.StatusUnauthorized)
+})
+
+// loginRequest is an attempt for alice from the given peer address
+func loginRequest(remoteAddr, password string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/login", nil)
+	req.RemoteAddr = remoteAddr
+	req.Header.Set("X-Username", "alice")
+	req.Header.Set("X-Password", password)
+	return req
+}
+
+func TestBruteForceLockout(t *testing.T) {
+	handler := BruteForceMiddleware(newFakeGuard(2, 100), BruteForceConfig{})(loginHandler)
+
+	status := []int{http.StatusUnauthorized, http.StatusUnauthorized, http.StatusTooManyRequests}
+	for i, want := range status {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, loginRequest("10.0.0.1:1234", "wrong"))
+		if rec.Code != want {
+			t.Errorf("attempt %d: got status %d, want %d", i+1, rec.Code, want)
+		}
+	}
+
+	// Spoofing X-Forwarded-For without a trusted proxy doesn't get a new IP
+	req := loginRequest("10.0.0.1:1234", "secret")
+	req.Header.Set("X-Forwarded-For", "203.0.113.7")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusTooManyRequests {
+		t.Errorf("got status %d with a spoofed X-Forwarded-For, want %d", rec.Code, http.StatusTooManyRequests)
+	}
+}
+
+func TestBruteForceChallenge(t *testing.T) {
+	tests := []struct {
+		name   string
+		solved bool
+		status int
+	}{
+		{name: "unsolved", status: http.StatusTooManyRequests},
+		{name: "solved", solved: true, status: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := BruteForceMiddleware(newFakeGuard(100, 3), BruteForceConfig{
+				VerifyChallenge: func(*http.Request) bool { return tt.solved },
+			})(loginHandler)
+
+			// Failures from different IPs never lock a single username+IP
+			for _, addr := range []string{"10.0.0.1:1", "10.0.0.2:1", "10.0.0.3:1"} {
+				handler.ServeHTTP(httptest.NewRecorder(), loginRequest(addr, "wrong"))
+			}
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, loginRequest("10.0.0.4:1", "secret"))
+			if rec.Code != tt.status {
+				t.Errorf("got status %d, want %d", rec.Code, tt.status)
+			}
+			if got := rec.Header().Get("X-Login-Challenge"); got != "required" {
+				t.Errorf("got challenge header %q, want required", got)
+			}
+		})
+	}
+}
+
+func TestTrustedProxyIPFunc(t *testing.T) {
+	ipFunc := TrustedProxyIPFunc([]netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")})
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  []string
+		want       string
+	}{
+		{name: "direct client", remoteAddr: "203.0.113.7:1234", want: "203.0.113.7"},
+		{name: "header from an untrusted peer", remoteAddr: "203.0.113.7:1234", forwarded: []string{"198.51.100.1"}, want: "203.0.113.7"},
+		{name: "behind a trusted proxy", remoteAddr: "10.0.0.1:1234", forwarded: []string{"203.0.113.7"}, want: "203.0.113.7"},
+		{name: "spoofed entries are left of the client", remoteAddr: "10.0.0.1:1234", forwarded: []string{"198.51.100.1, 203.0.113.7"}, want: "203.0.113.7"},
+		{name: "chain of trusted proxies", remoteAddr: "10.0.0.1:1234", forwarded: []string{"203.0.113.7", "10.0.0.2"}, want: "203.0.113.7"},
+		{name: "garbage from a trusted proxy", remoteAddr: "10.0.0.1:1234", forwarded: []string{"203.0.113.7, nonsense"}, want: "10.0.0.1"},
+		{name: "trusted proxy without header", remoteAddr: "10.0.0.1:1234", want: "10.0.0.1"},
+		{name: "IPv6 peer", remoteAddr: "[2001:db8::1]:1234", want: "2001:db8::1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for _, value := range tt.forwarded {
+				req.Header.Add("X-Forwarded-For", value)
+			}
+			if got := ipFunc(req); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
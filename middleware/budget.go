@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"sync"
+	"time"
+)
+
+// BudgetFallback selects the decision applied when the limiter doesn't
+// answer within the latency budget
+type BudgetFallback int
+
+const (
+	// BudgetAllow serves the request (fail open)
+	BudgetAllow BudgetFallback = iota
+	// BudgetDeny rejects the request (fail closed)
+	BudgetDeny
+	// BudgetLocal decides with the local limiter
+	BudgetLocal
+)
+
+// BudgetStats tracks how often limiter decisions exceed their latency budget
+type BudgetStats struct {
+	mu         sync.Mutex
+	decisions  int64
+	overruns   int64
+	maxLatency time.Duration
+}
+
+// BudgetReport is a snapshot of BudgetStats
+type BudgetReport struct {
+	Decisions  int64  `json:"decisions"`
+	Overruns   int64  `json:"overruns"`
+	MaxLatency string `json:"max_latency"`
+}
+
+// Record counts a decision that took elapsed, and whether it overran its budget
+func (s *BudgetStats) Record(elapsed time.Duration, overrun bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.decisions++
+	if overrun {
+		s.overruns++
+	}
+	if elapsed > s.maxLatency {
+		s.maxLatency = elapsed
+	}
+}
+
+// Report returns a snapshot of the counters
+func (s *BudgetStats) Report() BudgetReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return BudgetReport{
+		Decisions:  s.decisions,
+		Overruns:   s.overruns,
+		MaxLatency: s.maxLatency.String(),
+	}
+}
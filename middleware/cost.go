@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+)
+
+// CostHeader is the response header handlers can use to report the actual
+// cost of a request. It is removed before the response is sent.
+const CostHeader = "X-RateLimit-Cost"
+
+// requestCost holds the actual cost of a request as reported by the handler
+type requestCost struct {
+	cost atomic.Int64
+}
+
+type requestCostKey struct{}
+
+// newRequestCost creates a request cost initialized to the reserved cost
+func newRequestCost(reserved int) *requestCost {
+	c := &requestCost{}
+	c.cost.Store(int64(reserved))
+	return c
+}
+
+// Get returns the current cost
+func (c *requestCost) Get() int {
+	return int(c.cost.Load())
+}
+
+// readHeader takes the cost from the X-RateLimit-Cost response header
+func (c *requestCost) readHeader(h http.Header) {
+	value := h.Get(CostHeader)
+	if value == "" {
+		return
+	}
+	h.Del(CostHeader)
+
+	if cost, err := strconv.Atoi(value); err == nil && cost >= 0 {
+		c.cost.Store(int64(cost))
+	}
+}
+
+// SetCost sets the actual cost of the current request. It reports false if the
+// request is not handled by a rate limit middleware with AdjustCost or
+// CountStatus enabled.
+func SetCost(ctx context.Context, cost int) bool {
+	c, ok := ctx.Value(requestCostKey{}).(*requestCost)
+	if !ok || cost < 0 {
+		return false
+	}
+	c.cost.Store(int64(cost))
+	return true
+}
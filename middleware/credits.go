@@ -0,0 +1,172 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"rate-limiter/internal/limitter"
+)
+
+// CreditConfig holds configuration for credit-based access
+type CreditConfig struct {
+	// KeyFunc extracts the key whose balance is charged (e.g., API key)
+	KeyFunc func(*http.Request) string
+	// CostFunc returns the number of credits a request costs (default 1).
+	// Requests whose cost is not positive are rejected with OnInvalidCost.
+	CostFunc func(*http.Request) int64
+	// SkipFunc determines if charging should be skipped for this request
+	SkipFunc func(*http.Request) bool
+	// RefundStatus returns the credits of requests whose response status matches
+	RefundStatus func(status int) bool
+	// OnInsufficientCredits is called when the balance doesn't cover the cost
+	OnInsufficientCredits func(http.ResponseWriter, *http.Request, string)
+	// FailOpen serves requests free of charge when the balance can't be
+	// charged. By default they are rejected with OnCreditsUnavailable.
+	FailOpen bool
+	// OnCreditsUnavailable is called when the balance can't be charged
+	OnCreditsUnavailable func(http.ResponseWriter, *http.Request, string)
+	// OnInvalidCost is called when CostFunc returns a cost that is not positive
+	OnInvalidCost func(http.ResponseWriter, *http.Request, string)
+}
+
+// CreditMiddleware charges every request against a prepaid credit balance.
+// Requests that the balance doesn't cover are rejected with 402 Payment
+// Required, requests with an invalid cost with 400 Bad Request and requests
+// that can't be charged with 503 Service Unavailable.
+func CreditMiddleware(store *limitter.CreditStore, config CreditConfig) func(http.Handler) http.Handler {
+	// Set default values
+	if config.KeyFunc == nil {
+		config.KeyFunc = APIKeyFunc
+	}
+	if config.OnInsufficientCredits == nil {
+		config.OnInsufficientCredits = defaultOnInsufficientCredits
+	}
+	if config.OnCreditsUnavailable == nil {
+		config.OnCreditsUnavailable = defaultOnCreditsUnavailable
+	}
+	if config.OnInvalidCost == nil {
+		config.OnInvalidCost = defaultOnInvalidCost
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if config.SkipFunc != nil && config.SkipFunc(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			key := config.KeyFunc(r)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			cost := int64(1)
+			if config.CostFunc != nil {
+				cost = config.CostFunc(r)
+			}
+
+			ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+			result, err := store.Spend(ctx, key, cost)
+			cancel()
+			if errors.Is(err, limitter.ErrInvalidAmount) {
+				config.OnInvalidCost(w, r, key)
+				return
+			}
+			if err != nil {
+				// Paid requests are only served for free when configured to
+				if config.FailOpen {
+					next.ServeHTTP(w, r)
+					return
+				}
+				config.OnCreditsUnavailable(w, r, key)
+				return
+			}
+
+			// Set credit headers
+			w.Header().Set("X-Credits-Remaining", strconv.FormatInt(result.Balance, 10))
+			w.Header().Set("X-Credits-Cost", strconv.FormatInt(cost, 10))
+			if result.LowBalance {
+				w.Header().Set("X-Credits-Low", "true")
+			}
+
+			if !result.Allowed {
+				config.OnInsufficientCredits(w, r, key)
+				return
+			}
+
+			if config.RefundStatus == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			settleCtx, settleCancel := context.WithTimeout(context.WithoutCancel(r.Context()), 5*time.Second)
+			defer settleCancel()
+			defer func() {
+				if p := recover(); p != nil {
+					result.Reservation.Refund(settleCtx)
+					panic(p)
+				}
+			}()
+
+			rec := newResponseRecorder(w)
+			next.ServeHTTP(rec, r)
+
+			if config.RefundStatus(rec.Status()) {
+				result.Reservation.Refund(settleCtx)
+				return
+			}
+			result.Reservation.Commit(settleCtx)
+		})
+	}
+}
+
+// defaultOnInsufficientCredits handles requests the balance doesn't cover
+func defaultOnInsufficientCredits(w http.ResponseWriter, r *http.Request, key string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusPaymentRequired)
+
+	response := fmt.Sprintf(`{
+		"error": "Payment Required",
+		"message": "Insufficient credits. Please top up your balance.",
+		"code": %d,
+		"timestamp": "%s"
+	}`, http.StatusPaymentRequired, time.Now().UTC().Format(time.RFC3339))
+
+	w.Write([]byte(response))
+}
+
+// defaultOnCreditsUnavailable handles requests whose credits can't be charged
+func defaultOnCreditsUnavailable(w http.ResponseWriter, r *http.Request, key string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Retry-After", "1")
+	w.WriteHeader(http.StatusServiceUnavailable)
+
+	response := fmt.Sprintf(`{
+		"error": "Service Unavailable",
+		"message": "Credits could not be charged. Please try again later.",
+		"code": %d,
+		"timestamp": "%s"
+	}`, http.StatusServiceUnavailable, time.Now().UTC().Format(time.RFC3339))
+
+	w.Write([]byte(response))
+}
+
+// defaultOnInvalidCost handles requests whose cost is not positive
+func defaultOnInvalidCost(w http.ResponseWriter, r *http.Request, key string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+
+	response := fmt.Sprintf(`{
+		"error": "Bad Request",
+		"message": "The request has an invalid credit cost.",
+		"code": %d,
+		"timestamp": "%s"
+	}`, http.StatusBadRequest, time.Now().UTC().Format(time.RFC3339))
+
+	w.Write([]byte(response))
+}
@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"context"
+)
+
+// DegradationHeader is the request header carrying the degradation level upstream
+const DegradationHeader = "X-RateLimit-Degradation"
+
+// DegradationLevel tells handlers how close a client is to its limit so they
+// can serve cheaper responses before requests start being rejected
+type DegradationLevel int
+
+const (
+	// DegradationNormal means the client is well within its limit
+	DegradationNormal DegradationLevel = iota
+	// DegradationSoft means the client passed the soft threshold
+	DegradationSoft
+	// DegradationHard means the client passed the hard threshold
+	DegradationHard
+)
+
+// String returns the header value of the level
+func (l DegradationLevel) String() string {
+	switch l {
+	case DegradationSoft:
+		return "soft"
+	case DegradationHard:
+		return "hard"
+	default:
+		return "normal"
+	}
+}
+
+type degradationKey struct{}
+
+// DegradationFromContext returns the degradation level attached by the rate
+// limit middleware, or DegradationNormal if there is none
+func DegradationFromContext(ctx context.Context) DegradationLevel {
+	if level, ok := ctx.Value(degradationKey{}).(DegradationLevel); ok {
+		return level
+	}
+	return DegradationNormal
+}
+
+// degradationLevel maps the used fraction of the limit to a degradation level
+func degradationLevel(used, limit int, soft, hard float64) DegradationLevel {
+	if limit <= 0 {
+		return DegradationNormal
+	}
+
+	fraction := float64(used) / float64(limit)
+	switch {
+	case hard > 0 && fraction >= hard:
+		return DegradationHard
+	case soft > 0 && fraction >= soft:
+		return DegradationSoft
+	default:
+		return DegradationNormal
+	}
+}
@@ -0,0 +1,90 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeLimiter is an in-memory Limiter counting requests per key
+type fakeLimiter struct {
+	mu   sync.Mutex
+	used map[string]int
+}
+
+func newFakeLimiter() *fakeLimiter {
+	return &fakeLimiter{used: make(map[string]int)}
+}
+
+func (l *fakeLimiter) Allow(ctx context.Context, key string, limit int, window time.Duration) (bool, int, time.Time, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.used[key]++
+	return l.used[key] <= limit, max(limit-l.used[key], 0), time.Now().Add(window), nil
+}
+
+func TestDegradationLevel(t *testing.T) {
+	tests := []struct {
+		name       string
+		used       int
+		soft, hard float64
+		want       DegradationLevel
+	}{
+		{name: "below soft", used: 4, soft: 0.5, hard: 0.8, want: DegradationNormal},
+		{name: "at soft", used: 5, soft: 0.5, hard: 0.8, want: DegradationSoft},
+		{name: "at hard", used: 8, soft: 0.5, hard: 0.8, want: DegradationHard},
+		{name: "hard only", used: 7, hard: 0.8, want: DegradationNormal},
+		{name: "soft only", used: 10, soft: 0.5, want: DegradationSoft},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := degradationLevel(tt.used, 10, tt.soft, tt.hard); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDegradationMiddleware(t *testing.T) {
+	var levels, headers []string
+	handler := RateLimitMiddleware(newFakeLimiter(), RateLimitConfig{
+		MaxRequests:   4,
+		KeyFunc:       func(*http.Request) string { return "client" },
+		SoftThreshold: 0.5,
+		HardThreshold: 0.75,
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		levels = append(levels, DegradationFromContext(r.Context()).String())
+		headers = append(headers, r.Header.Get(DegradationHeader))
+	}))
+
+	for i := 0; i < 4; i++ {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	}
+
+	want := []string{"normal", "soft", "hard", "hard"}
+	for i := range want {
+		if levels[i] != want[i] || headers[i] != want[i] {
+			t.Errorf("request %d: got level %q and header %q, want %q", i+1, levels[i], headers[i], want[i])
+		}
+	}
+}
+
+func TestDegradationDisabled(t *testing.T) {
+	handler := RateLimitMiddleware(newFakeLimiter(), RateLimitConfig{
+		MaxRequests: 1,
+		KeyFunc:     func(*http.Request) string { return "client" },
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get(DegradationHeader); got != "" {
+			t.Errorf("got degradation header %q without thresholds", got)
+		}
+		if got := DegradationFromContext(r.Context()); got != DegradationNormal {
+			t.Errorf("got level %v without thresholds", got)
+		}
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+}
@@ -0,0 +1,108 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"rate-limiter/internal/limitter"
+)
+
+// DistinctConfig holds configuration for distinct-value limiting
+type DistinctConfig struct {
+	// WindowSize is the time window for counting distinct values
+	WindowSize time.Duration
+	// MaxDistinct is the number of distinct values allowed per key in the window
+	MaxDistinct int
+	// KeyFunc extracts the key from the request (e.g., IP address, API key)
+	KeyFunc func(*http.Request) string
+	// ValueFunc extracts the value whose distinct count is limited (e.g., username)
+	ValueFunc func(*http.Request) string
+	// SkipFunc determines if limiting should be skipped for this request
+	SkipFunc func(*http.Request) bool
+	// OnLimitExceeded is called when too many distinct values were used
+	OnLimitExceeded func(http.ResponseWriter, *http.Request, string)
+}
+
+// DistinctMiddleware limits how many distinct values a key may use per
+// window, e.g. "an IP may try at most 20 distinct usernames per hour"
+func DistinctMiddleware(limiter limitter.DistinctLimiter, config DistinctConfig) func(http.Handler) http.Handler {
+	// Set default values
+	if config.WindowSize == 0 {
+		config.WindowSize = time.Hour
+	}
+	if config.MaxDistinct == 0 {
+		config.MaxDistinct = 100
+	}
+	if config.KeyFunc == nil {
+		config.KeyFunc = defaultKeyFunc
+	}
+	if config.ValueFunc == nil {
+		config.ValueFunc = PathValueFunc
+	}
+	if config.OnLimitExceeded == nil {
+		config.OnLimitExceeded = defaultOnLimitExceeded
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if config.SkipFunc != nil && config.SkipFunc(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			key := config.KeyFunc(r)
+			value := config.ValueFunc(r)
+			if key == "" || value == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			distinctKey := fmt.Sprintf("rate_limit:distinct:%s", key)
+
+			ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+			defer cancel()
+
+			result, err := limiter.IsAllowedDistinct(ctx, distinctKey, value, config.MaxDistinct, config.WindowSize)
+			if err != nil {
+				// Don't block the request if the limiter is unavailable
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// Set distinct limit headers
+			w.Header().Set("X-RateLimit-Distinct-Limit", strconv.Itoa(config.MaxDistinct))
+			w.Header().Set("X-RateLimit-Distinct-Remaining", strconv.Itoa(result.Remaining))
+			w.Header().Set("X-RateLimit-Distinct-Reset", strconv.FormatInt(result.ResetTime.Unix(), 10))
+
+			if !result.Allowed {
+				w.Header().Set("Retry-After", strconv.FormatInt(int64(result.RetryAfter.Seconds()), 10))
+				config.OnLimitExceeded(w, r, key)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Predefined value functions for distinct-value limiting
+
+// PathValueFunc uses the request path as the value, e.g. distinct resource IDs
+func PathValueFunc(r *http.Request) string {
+	return r.URL.Path
+}
+
+// UsernameValueFunc uses the attempted username as the value
+func UsernameValueFunc(r *http.Request) string {
+	return defaultUsernameFunc(r)
+}
+
+// HeaderValueFunc uses the given request header as the value
+func HeaderValueFunc(header string) func(*http.Request) string {
+	return func(r *http.Request) string {
+		return r.Header.Get(header)
+	}
+}
@@ -0,0 +1,109 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"rate-limiter/internal/limitter"
+)
+
+// fakeDistinct is an in-memory DistinctLimiter with exact counts
+type fakeDistinct struct {
+	seen map[string]map[string]bool
+	err  error
+}
+
+func (d *fakeDistinct) IsAllowedDistinct(ctx context.Context, key, value string, limit int, window time.Duration) (*limitter.RateLimitResult, error) {
+	if d.err != nil {
+		return nil, d.err
+	}
+	if d.seen[key] == nil {
+		d.seen[key] = make(map[string]bool)
+	}
+	values := d.seen[key]
+	allowed := values[value] || len(values) < limit
+	if allowed {
+		values[value] = true
+	}
+	return &limitter.RateLimitResult{
+		Allowed:   allowed,
+		Remaining: limit - len(values),
+		ResetTime: time.Now().Add(window),
+	}, nil
+}
+
+func TestDistinctMiddleware(t *testing.T) {
+	tests := []struct {
+		name   string
+		paths  []string
+		err    error
+		status []int
+	}{
+		{
+			name:   "distinct paths up to the limit",
+			paths:  []string{"/a", "/b", "/a"},
+			status: []int{http.StatusOK, http.StatusOK, http.StatusOK},
+		},
+		{
+			name:   "new path over the limit",
+			paths:  []string{"/a", "/b", "/c", "/b"},
+			status: []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests, http.StatusOK},
+		},
+		{
+			name:   "limiter errors don't block",
+			paths:  []string{"/a", "/b", "/c"},
+			err:    errors.New("unavailable"),
+			status: []int{http.StatusOK, http.StatusOK, http.StatusOK},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter := &fakeDistinct{seen: make(map[string]map[string]bool), err: tt.err}
+			handler := DistinctMiddleware(limiter, DistinctConfig{
+				MaxDistinct: 2,
+				KeyFunc:     func(*http.Request) string { return "client" },
+			})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+			for i, path := range tt.paths {
+				rec := httptest.NewRecorder()
+				handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+				if rec.Code != tt.status[i] {
+					t.Errorf("%s: got status %d, want %d", path, rec.Code, tt.status[i])
+				}
+			}
+		})
+	}
+}
+
+func TestDistinctHeaders(t *testing.T) {
+	limiter := &fakeDistinct{seen: make(map[string]map[string]bool)}
+	handler := DistinctMiddleware(limiter, DistinctConfig{
+		MaxDistinct: 5,
+		KeyFunc:     func(*http.Request) string { return "client" },
+		ValueFunc:   HeaderValueFunc("X-Resource"),
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Resource", "42")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if got := rec.Header().Get("X-RateLimit-Distinct-Limit"); got != "5" {
+		t.Errorf("got limit header %q, want 5", got)
+	}
+	if got := rec.Header().Get("X-RateLimit-Distinct-Remaining"); got != "4" {
+		t.Errorf("got remaining header %q, want 4", got)
+	}
+
+	// Requests without a value are not limited
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if got := rec.Header().Get("X-RateLimit-Distinct-Remaining"); got != "" {
+		t.Errorf("got remaining header %q for a request without value", got)
+	}
+}
@@ -0,0 +1,95 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"rate-limiter/internal/limitter"
+)
+
+// maxFingerprintBody is the largest request body hashed into an idempotency
+// fingerprint. Requests with larger bodies are charged without tracking.
+const maxFingerprintBody = 10 << 20
+
+// errBodyTooLarge is returned for bodies that are not fingerprinted
+var errBodyTooLarge = errors.New("request body too large to fingerprint")
+
+// requestFingerprint hashes the method, URI and body of r, and restores the
+// body so the handler can still read it
+func requestFingerprint(r *http.Request) (string, error) {
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%s %s\n", r.Method, r.URL.RequestURI())
+
+	if r.Body != nil && r.Body != http.NoBody {
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxFingerprintBody+1))
+		r.Body = &replayedBody{Reader: io.MultiReader(bytes.NewReader(body), r.Body), body: r.Body}
+		if err != nil {
+			return "", fmt.Errorf("failed to read request body: %w", err)
+		}
+		if len(body) > maxFingerprintBody {
+			return "", errBodyTooLarge
+		}
+		hash.Write(body)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// replayedBody serves the bytes read for the fingerprint before the rest of the body
+type replayedBody struct {
+	io.Reader
+	body io.Closer
+}
+
+func (b *replayedBody) Close() error {
+	return b.body.Close()
+}
+
+// completeIdempotency wraps next so the idempotency key is marked as served
+// once it returns, or forgotten when it panics so the retry is charged
+func completeIdempotency(next http.Handler, tracker *limitter.IdempotencyTracker, key, idempotencyKey, fingerprint string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The request context may already be cancelled once the response is written
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), 5*time.Second)
+		defer cancel()
+
+		defer func() {
+			if p := recover(); p != nil {
+				tracker.Forget(ctx, key, idempotencyKey)
+				panic(p)
+			}
+		}()
+
+		next.ServeHTTP(w, r)
+		tracker.Complete(ctx, key, idempotencyKey, fingerprint)
+	})
+}
+
+// forgetIdempotency forgets an idempotency key whose request was not served,
+// so that its retry is charged
+func forgetIdempotency(r *http.Request, tracker *limitter.IdempotencyTracker, key, idempotencyKey string) {
+	ctx, cancel := sideEffectContext(r)
+	defer cancel()
+	tracker.Forget(ctx, key, idempotencyKey)
+}
+
+// writeIdempotencyError rejects a request whose idempotency key can't be honored
+func writeIdempotencyError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	response := fmt.Sprintf(`{
+		"error": "%s",
+		"message": "%s",
+		"code": %d,
+		"timestamp": "%s"
+	}`, http.StatusText(status), message, status, time.Now().UTC().Format(time.RFC3339))
+
+	w.Write([]byte(response))
+}
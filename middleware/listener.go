@@ -0,0 +1,196 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// ListenerConfig holds configuration for connection-level limiting
+type ListenerConfig struct {
+	// WindowSize is the time window for the new connection rate
+	WindowSize time.Duration
+	// MaxConnections is the number of new connections allowed per IP in the
+	// window (0 disables the rate limit)
+	MaxConnections int
+	// MaxConcurrent is the number of open connections allowed per IP
+	// (0 disables the concurrency limit)
+	MaxConcurrent int
+	// Timeout bounds the limiter call made for every accepted connection
+	// (default 50ms). Each call runs in its own goroutine, so a slow limiter
+	// only delays the connections it is checking.
+	Timeout time.Duration
+}
+
+// acceptResult is a connection that passed the limits, or an accept error
+type acceptResult struct {
+	conn net.Conn
+	err  error
+}
+
+// limitedListener wraps a net.Listener and closes connections that exceed the
+// per-IP connection rate or concurrency limit
+type limitedListener struct {
+	net.Listener
+	limiter Limiter
+	config  ListenerConfig
+
+	mu     sync.Mutex
+	active map[string]int
+
+	start   sync.Once
+	results chan acceptResult
+	stop    sync.Once
+	done    chan struct{}
+}
+
+// LimitListener wraps ln so that connection floods are rejected before any
+// HTTP parsing happens. limiter may be nil to only limit concurrency.
+func LimitListener(ln net.Listener, limiter Limiter, config ListenerConfig) net.Listener {
+	// Set default values
+	if config.WindowSize == 0 {
+		config.WindowSize = time.Minute
+	}
+	if config.Timeout == 0 {
+		config.Timeout = 50 * time.Millisecond
+	}
+
+	return &limitedListener{
+		Listener: ln,
+		limiter:  limiter,
+		config:   config,
+		active:   make(map[string]int),
+		results:  make(chan acceptResult),
+		done:     make(chan struct{}),
+	}
+}
+
+// Accept waits for the next connection that is within the limits
+func (l *limitedListener) Accept() (net.Conn, error) {
+	l.start.Do(func() { go l.acceptLoop() })
+
+	select {
+	case result := <-l.results:
+		return result.conn, result.err
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+// Close stops accepting connections and closes the underlying listener
+func (l *limitedListener) Close() error {
+	l.stop.Do(func() { close(l.done) })
+	return l.Listener.Close()
+}
+
+// acceptLoop accepts connections and checks each one in its own goroutine,
+// so connections from other IPs don't wait for the limiter
+func (l *limitedListener) acceptLoop() {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			if !l.deliver(acceptResult{err: err}) || errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+
+		// The concurrency limit is local and decided right away
+		ip := remoteIP(conn)
+		if !l.acquire(ip) {
+			conn.Close()
+			continue
+		}
+
+		go l.admit(conn, ip)
+	}
+}
+
+// admit hands conn to Accept if it is within the rate limit
+func (l *limitedListener) admit(conn net.Conn, ip string) {
+	if !l.allowRate(ip) {
+		l.release(ip)
+		conn.Close()
+		return
+	}
+
+	limited := &limitedConn{Conn: conn, release: func() { l.release(ip) }}
+	if !l.deliver(acceptResult{conn: limited}) {
+		limited.Close()
+	}
+}
+
+// deliver passes result to Accept and reports false if the listener was
+// closed first
+func (l *limitedListener) deliver(result acceptResult) bool {
+	select {
+	case l.results <- result:
+		return true
+	case <-l.done:
+		return false
+	}
+}
+
+// allowRate checks the new connection rate for ip, failing open on errors
+func (l *limitedListener) allowRate(ip string) bool {
+	if l.limiter == nil || l.config.MaxConnections <= 0 {
+		return true
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), l.config.Timeout)
+	defer cancel()
+
+	allowed, _, _, err := l.limiter.Allow(ctx, fmt.Sprintf("rate_limit:conn:%s", ip), l.config.MaxConnections, l.config.WindowSize)
+	if err != nil {
+		return true
+	}
+	return allowed
+}
+
+// acquire reserves a concurrent connection slot for ip
+func (l *limitedListener) acquire(ip string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.config.MaxConcurrent > 0 && l.active[ip] >= l.config.MaxConcurrent {
+		return false
+	}
+	l.active[ip]++
+	return true
+}
+
+// release frees a concurrent connection slot for ip
+func (l *limitedListener) release(ip string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.active[ip]--
+	if l.active[ip] <= 0 {
+		delete(l.active, ip)
+	}
+}
+
+// limitedConn releases its concurrency slot when closed
+type limitedConn struct {
+	net.Conn
+	once    sync.Once
+	release func()
+}
+
+func (c *limitedConn) Close() error {
+	err := c.Conn.Close()
+	c.once.Do(c.release)
+	return err
+}
+
+// remoteIP returns the IP address of the connection's remote end
+func remoteIP(conn net.Conn) string {
+	addr := conn.RemoteAddr().String()
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
@@ -0,0 +1,149 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// blockingLimiter allows every connection, but holds the first check until
+// release is closed
+type blockingLimiter struct {
+	calls   atomic.Int32
+	started chan struct{}
+	release chan struct{}
+}
+
+func (l *blockingLimiter) Allow(ctx context.Context, key string, limit int, window time.Duration) (bool, int, time.Time, error) {
+	if l.calls.Add(1) == 1 {
+		close(l.started)
+		<-l.release
+	}
+	return true, limit, time.Now().Add(window), nil
+}
+
+// serveListener accepts connections of ln until it is closed and returns them
+func serveListener(t *testing.T, ln net.Listener) <-chan net.Conn {
+	t.Helper()
+	accepted := make(chan net.Conn, 16)
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			accepted <- conn
+		}
+	}()
+	return accepted
+}
+
+// closedByServer reports whether the server closed conn without a response
+func closedByServer(t *testing.T, conn net.Conn) bool {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+	_, err := conn.Read(make([]byte, 1))
+	var netErr net.Error
+	return err != nil && !(errors.As(err, &netErr) && netErr.Timeout())
+}
+
+func listen(t *testing.T) net.Listener {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ln
+}
+
+func dial(t *testing.T, ln net.Listener) net.Conn {
+	t.Helper()
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestListenerLimits(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   ListenerConfig
+		accepted int
+	}{
+		{name: "rate", config: ListenerConfig{MaxConnections: 2}, accepted: 2},
+		{name: "concurrency", config: ListenerConfig{MaxConcurrent: 3}, accepted: 3},
+		{name: "disabled", accepted: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ln := LimitListener(listen(t), newFakeLimiter(), tt.config)
+			accepted := serveListener(t, ln)
+
+			rejected := 0
+			for i := 0; i < 4; i++ {
+				if closedByServer(t, dial(t, ln)) {
+					rejected++
+				}
+			}
+			if got := len(accepted); got != tt.accepted || rejected != 4-tt.accepted {
+				t.Errorf("got %d accepted and %d rejected, want %d accepted", got, rejected, tt.accepted)
+			}
+		})
+	}
+}
+
+func TestListenerReleasesClosedConnections(t *testing.T) {
+	ln := LimitListener(listen(t), nil, ListenerConfig{MaxConcurrent: 1})
+	accepted := serveListener(t, ln)
+
+	dial(t, ln)
+	(<-accepted).Close()
+
+	if closedByServer(t, dial(t, ln)) {
+		t.Error("got the connection rejected after the previous one closed")
+	}
+}
+
+func TestListenerChecksOffTheAcceptLoop(t *testing.T) {
+	limiter := &blockingLimiter{started: make(chan struct{}), release: make(chan struct{})}
+	defer close(limiter.release)
+	ln := LimitListener(listen(t), limiter, ListenerConfig{MaxConnections: 10, Timeout: 5 * time.Second})
+	accepted := serveListener(t, ln)
+
+	dial(t, ln)
+	<-limiter.started
+
+	// The first check is still running, the second connection doesn't wait for it
+	dial(t, ln)
+	select {
+	case <-accepted:
+	case <-time.After(time.Second):
+		t.Fatal("got no connection while another one was checked")
+	}
+}
+
+func TestListenerClose(t *testing.T) {
+	ln := LimitListener(listen(t), newFakeLimiter(), ListenerConfig{MaxConnections: 10})
+	errs := make(chan error, 1)
+	go func() {
+		_, err := ln.Accept()
+		errs <- err
+	}()
+
+	ln.Close()
+	select {
+	case err := <-errs:
+		if !errors.Is(err, net.ErrClosed) {
+			t.Errorf("got error %v, want net.ErrClosed", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("got Accept still waiting after Close")
+	}
+}
@@ -2,11 +2,14 @@ package middleware
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
+
+	"rate-limiter/internal/limitter"
 )
 
 // Limiter interface defines the rate limiting operations
@@ -14,8 +17,32 @@ type Limiter interface {
 	Allow(ctx context.Context, key string, limit int, window time.Duration) (allowed bool, remaining int, resetTime time.Time, err error)
 }
 
+// Peeker is implemented by limiters that can report whether a key is over
+// its limit without recording a request
+type Peeker interface {
+	Peek(ctx context.Context, key string, limit int, window time.Duration) (allowed bool, remaining int, resetTime time.Time, err error)
+}
+
+// CostLimiter is implemented by limiters that support weighted requests and
+// adjusting a charge after the response
+type CostLimiter interface {
+	AllowN(ctx context.Context, key string, limit int, window time.Duration, n int) (allowed bool, remaining int, resetTime time.Time, err error)
+	Adjust(ctx context.Context, key string, window time.Duration, delta int) error
+}
+
+// Reserver is implemented by limiters whose charges can be refunded
+type Reserver interface {
+	Reserve(ctx context.Context, key string, limit int, window time.Duration, n int) (allowed bool, remaining int, resetTime time.Time, reservation limitter.Reservation, err error)
+}
+
+// ErrRejectedEarly is returned with allowed=false by limiters that rejected a
+// request before its key reached the limit, e.g. by early throttling
+var ErrRejectedEarly = errors.New("middleware: request rejected before the limit")
+
 // RateLimitConfig holds configuration for rate limiting
 type RateLimitConfig struct {
+	// Name identifies the policy in reports (default "default")
+	Name string
 	// WindowSize is the time window for rate limiting (e.g., 1 minute)
 	WindowSize time.Duration
 	// MaxRequests is the maximum number of requests allowed in the window
@@ -26,23 +53,106 @@ type RateLimitConfig struct {
 	SkipFunc func(*http.Request) bool
 	// OnLimitExceeded is called when rate limit is exceeded
 	OnLimitExceeded func(http.ResponseWriter, *http.Request, string)
+	// Bans escalates repeated violations into temporary bans (optional)
+	Bans *limitter.BanManager
+	// OnBanned is called when a banned client makes a request
+	OnBanned func(http.ResponseWriter, *http.Request, *limitter.BanRecord)
+	// CountStatus enables post-response accounting: only responses whose status
+	// matches are charged. Requests whose cost doesn't fit are still blocked up
+	// front when the limiter implements Peeker. Otherwise requests are charged
+	// up front and refunded when the status doesn't match, which needs a
+	// limiter implementing Reserver or CostLimiter.
+	CountStatus func(status int) bool
+	// CostFunc returns the initial cost reserved for a request (default 1)
+	CostFunc func(*http.Request) int
+	// AdjustCost lets handlers set the actual cost with SetCost or the
+	// X-RateLimit-Cost response header. The difference from the reserved
+	// cost is charged or refunded after the response.
+	AdjustCost bool
+	// HeavyHitters tracks the heaviest keys of the policy (optional)
+	HeavyHitters *limitter.HeavyHitterTracker
+	// Idempotency remembers Idempotency-Key headers per client so retries are
+	// not charged twice. A key is bound to the method, URI and body of the
+	// first request: reusing it for another request gets 422, and retrying
+	// while the first request runs gets 409. Retries are still checked
+	// against bans and against the limit with Peek, or charged when the
+	// limiter can't peek.
+	Idempotency *limitter.IdempotencyTracker
+	// RefundStatus refunds the charge of requests whose response status
+	// matches, e.g. RefundServerErrors. Requests whose handler panics are
+	// always refunded. Requires a limiter implementing Reserver.
+	RefundStatus func(status int) bool
+	// AllowOverage turns MaxRequests into a soft limit: requests above it are
+	// served up to HardLimit and recorded in Overage for billing
+	AllowOverage bool
+	// HardLimit is the ceiling enforced when AllowOverage is set (default 2x MaxRequests)
+	HardLimit int
+	// Overage records requests served above the soft limit (optional)
+	Overage *limitter.OverageRecorder
+	// SoftThreshold and HardThreshold are fractions of MaxRequests (e.g. 0.7
+	// and 0.9). Once passed, the degradation level is attached to the request
+	// context and sent upstream in the X-RateLimit-Degradation header.
+	SoftThreshold float64
+	HardThreshold float64
+	// Denials caches exhausted keys and rejects their retries without calling
+	// the limiter (optional). The cache is local to this process, so resets
+	// on other instances only take effect once its entries expire. Cached
+	// rejections are not counted as ban violations.
+	Denials *limitter.DenialCache
+	// LatencyBudget bounds how long the limiter may take to decide (default 5s)
+	LatencyBudget time.Duration
+	// BudgetFallback is the decision applied when the budget is exceeded
+	// (default BudgetAllow)
+	BudgetFallback BudgetFallback
+	// LocalLimiter decides with MaxRequests / Instances for BudgetLocal
+	LocalLimiter Limiter
+	// Instances is the expected number of instances sharing the limit (default 1)
+	Instances int
+	// BudgetStats tracks decisions that exceed the budget (optional)
+	BudgetStats *BudgetStats
+}
+
+// enforcedLimit returns the limit the limiter enforces for this policy
+func (c RateLimitConfig) enforcedLimit() int {
+	if c.AllowOverage {
+		return c.HardLimit
+	}
+	return c.MaxRequests
 }
 
 // RateLimitMiddleware creates a new rate limiting middleware
 func RateLimitMiddleware(limiter Limiter, config RateLimitConfig) func(http.Handler) http.Handler {
 	// Set default values
+	if config.Name == "" {
+		config.Name = "default"
+	}
 	if config.WindowSize == 0 {
 		config.WindowSize = time.Minute
 	}
 	if config.MaxRequests == 0 {
 		config.MaxRequests = 100
 	}
+	if config.AllowOverage && config.HardLimit < config.MaxRequests {
+		config.HardLimit = 2 * config.MaxRequests
+	}
 	if config.KeyFunc == nil {
 		config.KeyFunc = defaultKeyFunc
 	}
 	if config.OnLimitExceeded == nil {
 		config.OnLimitExceeded = defaultOnLimitExceeded
 	}
+	if config.OnBanned == nil {
+		config.OnBanned = defaultOnBanned
+	}
+	if config.LatencyBudget <= 0 {
+		config.LatencyBudget = 5 * time.Second
+	}
+	if config.Instances <= 0 {
+		config.Instances = 1
+	}
+	if config.BudgetFallback == BudgetLocal && config.LocalLimiter == nil {
+		config.BudgetFallback = BudgetAllow
+	}
 
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -63,18 +173,183 @@ func RateLimitMiddleware(limiter Limiter, config RateLimitConfig) func(http.Hand
 			// Create rate limit key with prefix
 			rateLimitKey := fmt.Sprintf("rate_limit:%s", key)
 
-			// Check rate limit
-			ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+			// Banned clients are rejected before the limiter runs
+			if config.Bans != nil {
+				banCtx, cancelBan := sideEffectContext(r)
+				ban, err := config.Bans.CheckBan(banCtx, key)
+				cancelBan()
+				if err == nil && ban != nil {
+					writeBanHeaders(w, ban)
+					config.OnBanned(w, r, ban)
+					return
+				}
+			}
+
+			// Keys known to be exhausted are rejected locally
+			if config.Denials != nil {
+				if until, denied := config.Denials.Denied(rateLimitKey); denied {
+					w.Header().Set("X-RateLimit-Limit", strconv.Itoa(config.MaxRequests))
+					w.Header().Set("X-RateLimit-Remaining", "0")
+					w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(until.Unix(), 10))
+					w.Header().Set("Retry-After", strconv.FormatInt(int64(time.Until(until).Seconds()), 10))
+					config.OnLimitExceeded(w, r, key)
+					return
+				}
+			}
+
+			cost := 1
+			if config.CostFunc != nil {
+				// A negative cost would credit the client, so it counts as one unit
+				if c := config.CostFunc(r); c >= 0 {
+					cost = c
+				}
+			}
+			limit := config.enforcedLimit()
+
+			// Retries carrying a known idempotency key are not charged again
+			idempotencyKey := r.Header.Get("Idempotency-Key")
+			var fingerprint string
+			duplicate := false
+			if config.Idempotency != nil && idempotencyKey != "" {
+				var state limitter.IdempotencyState
+				var err error
+				fingerprint, err = requestFingerprint(r)
+				if err == nil {
+					idemCtx, cancelIdem := sideEffectContext(r)
+					state, err = config.Idempotency.Remember(idemCtx, key, idempotencyKey, fingerprint)
+					cancelIdem()
+				}
+				switch {
+				case errors.Is(err, limitter.ErrIdempotencyMismatch):
+					writeIdempotencyError(w, http.StatusUnprocessableEntity, "Idempotency-Key was already used for a different request.")
+					return
+				case err != nil:
+					idempotencyKey = ""
+				case state == limitter.IdempotencyInFlight:
+					writeIdempotencyError(w, http.StatusConflict, "A request with this Idempotency-Key is still being processed.")
+					return
+				case state == limitter.IdempotencyDone:
+					duplicate = true
+				}
+			}
+			tracked := config.Idempotency != nil && idempotencyKey != "" && !duplicate
+
+			// Only the limiter decision runs within the latency budget
+			start := time.Now()
+			ctx, cancel := context.WithTimeout(r.Context(), config.LatencyBudget)
 			defer cancel()
 
-			allowed, remaining, resetTime, err := limiter.Allow(ctx, rateLimitKey, config.MaxRequests, config.WindowSize)
+			var allowed bool
+			var remaining int
+			var resetTime time.Time
+			var reservation limitter.Reservation
+			var err error
+			precharged := false
+			retryCharged := false
+			reserver, canReserve := limiter.(Reserver)
+			if duplicate {
+				peeker, ok := limiter.(Peeker)
+				if ok {
+					allowed, remaining, resetTime, err = peeker.Peek(ctx, rateLimitKey, limit, config.WindowSize)
+				}
+				if !ok || errors.Is(err, limitter.ErrPeekUnsupported) {
+					// Without a peek the retry is charged like a new request
+					retryCharged = true
+					if costLimiter, ok := limiter.(CostLimiter); ok && cost != 1 {
+						allowed, remaining, resetTime, err = costLimiter.AllowN(ctx, rateLimitKey, limit, config.WindowSize, cost)
+					} else {
+						allowed, remaining, resetTime, err = limiter.Allow(ctx, rateLimitKey, limit, config.WindowSize)
+					}
+				}
+			} else if config.CountStatus != nil {
+				// Only check the current state, the request is charged after the response
+				peeker, ok := limiter.(Peeker)
+				if ok {
+					allowed, remaining, resetTime, err = peeker.Peek(ctx, rateLimitKey, limit, config.WindowSize)
+					// The request must fit in what is left, not only find the key below its limit
+					allowed = allowed && remaining >= cost
+				}
+				if !ok || errors.Is(err, limitter.ErrPeekUnsupported) {
+					// Without a peek the request is charged up front and
+					// refunded after the response unless its status counts
+					precharged = true
+					if canReserve {
+						allowed, remaining, resetTime, reservation, err = reserver.Reserve(ctx, rateLimitKey, limit, config.WindowSize, cost)
+					} else if costLimiter, ok := limiter.(CostLimiter); ok {
+						allowed, remaining, resetTime, err = costLimiter.AllowN(ctx, rateLimitKey, limit, config.WindowSize, cost)
+					} else {
+						allowed, remaining, resetTime, err = limiter.Allow(ctx, rateLimitKey, limit, config.WindowSize)
+					}
+				}
+			} else if config.RefundStatus != nil && canReserve {
+				allowed, remaining, resetTime, reservation, err = reserver.Reserve(ctx, rateLimitKey, limit, config.WindowSize, cost)
+			} else if costLimiter, ok := limiter.(CostLimiter); ok && cost != 1 {
+				allowed, remaining, resetTime, err = costLimiter.AllowN(ctx, rateLimitKey, limit, config.WindowSize, cost)
+			} else {
+				allowed, remaining, resetTime, err = limiter.Allow(ctx, rateLimitKey, limit, config.WindowSize)
+			}
+
+			// Early rejections are not violations and don't exhaust the key
+			early := errors.Is(err, ErrRejectedEarly)
+			if early {
+				allowed, err = false, nil
+			}
+
+			handler := next
+			if tracked {
+				handler = completeIdempotency(next, config.Idempotency, key, idempotencyKey, fingerprint)
+			}
+
+			elapsed := time.Since(start)
+			overrun := elapsed > config.LatencyBudget || (err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded))
+			if config.BudgetStats != nil {
+				config.BudgetStats.Record(elapsed, overrun)
+			}
+
+			if err != nil && overrun {
+				switch config.BudgetFallback {
+				case BudgetDeny:
+					if tracked {
+						forgetIdempotency(r, config.Idempotency, key, idempotencyKey)
+					}
+					w.Header().Set("Retry-After", "1")
+					config.OnLimitExceeded(w, r, key)
+					return
+				case BudgetLocal:
+					// The budget is spent, the local decision doesn't need a deadline
+					localCtx := context.WithoutCancel(r.Context())
+					allowed, remaining, resetTime, err = config.LocalLimiter.Allow(localCtx, rateLimitKey, max(limit/config.Instances, 1), config.WindowSize)
+				}
+			}
 			if err != nil {
 				// Log error but don't block request
 				// In production, you might want to handle this differently
-				next.ServeHTTP(w, r)
+				handler.ServeHTTP(w, r)
 				return
 			}
 
+			charged := !duplicate || retryCharged
+			if config.HeavyHitters != nil && charged {
+				recordCtx, cancelRecord := sideEffectContext(r)
+				config.HeavyHitters.Record(recordCtx, config.Name, key, int64(cost))
+				cancelRecord()
+			}
+
+			// Requests above the soft limit are served as overage
+			if config.AllowOverage {
+				used := limit - remaining
+				remaining = max(config.MaxRequests-used, 0)
+				if allowed && charged && used > config.MaxRequests {
+					w.Header().Set("X-RateLimit-Overage", strconv.Itoa(used-config.MaxRequests))
+					if config.Overage != nil {
+						overageCtx, cancelOverage := sideEffectContext(r)
+						config.Overage.Record(overageCtx, config.Name, key, int64(cost))
+						cancelOverage()
+					}
+				}
+				w.Header().Set("X-RateLimit-Hard-Limit", strconv.Itoa(limit))
+			}
+
 			// Set rate limit headers
 			w.Header().Set("X-RateLimit-Limit", strconv.Itoa(config.MaxRequests))
 			w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
@@ -83,16 +358,128 @@ func RateLimitMiddleware(limiter Limiter, config RateLimitConfig) func(http.Hand
 			if !allowed {
 				// Rate limit exceeded
 				w.Header().Set("Retry-After", strconv.FormatInt(int64(time.Until(resetTime).Seconds()), 10))
+
+				// Retries of an exhausted key are answered from the cache
+				if config.Denials != nil && remaining == 0 && !early {
+					config.Denials.Deny(rateLimitKey, resetTime)
+				}
+
+				// A rejected first attempt was not served, so its retry must be charged
+				if tracked {
+					forgetIdempotency(r, config.Idempotency, key, idempotencyKey)
+				}
+
+				// Repeated violations escalate to a temporary ban
+				if config.Bans != nil && !early {
+					banCtx, cancelBan := sideEffectContext(r)
+					ban, err := config.Bans.RecordViolation(banCtx, key)
+					cancelBan()
+					if err == nil && ban != nil {
+						writeBanHeaders(w, ban)
+						config.OnBanned(w, r, ban)
+						return
+					}
+				}
+
 				config.OnLimitExceeded(w, r, key)
 				return
 			}
 
+			// Let handlers serve cheaper responses as the client nears its limit
+			if config.SoftThreshold > 0 || config.HardThreshold > 0 {
+				level := degradationLevel(config.MaxRequests-remaining, config.MaxRequests, config.SoftThreshold, config.HardThreshold)
+				r = r.WithContext(context.WithValue(r.Context(), degradationKey{}, level))
+				r.Header.Set(DegradationHeader, level.String())
+			}
+
+			if duplicate {
+				handler.ServeHTTP(w, r)
+				return
+			}
+
+			if config.CountStatus != nil || config.AdjustCost || reservation != nil {
+				serveAndSettle(limiter, config, rateLimitKey, cost, reservation, config.CountStatus == nil || precharged, w, r, handler)
+				return
+			}
+
 			// Request is allowed, proceed
-			next.ServeHTTP(w, r)
+			handler.ServeHTTP(w, r)
 		})
 	}
 }
 
+// sideEffectTimeout bounds each Redis call made besides the limiter decision
+const sideEffectTimeout = time.Second
+
+// sideEffectContext returns the context of one Redis call made besides the
+// limiter decision. Such calls don't count against the latency budget and
+// complete even if the client goes away.
+func sideEffectContext(r *http.Request) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithoutCancel(r.Context()), sideEffectTimeout)
+}
+
+// serveAndSettle serves the request and settles its charge afterwards. With
+// CountStatus set and the request not charged yet, the actual cost is charged
+// only for matching responses, even if the key filled up meanwhile. A charged
+// request is refunded if its status doesn't match CountStatus, otherwise the
+// difference from the charged cost is charged or refunded. A reservation is
+// refunded if the handler panics, the response status matches RefundStatus or
+// it doesn't match CountStatus, and committed otherwise.
+func serveAndSettle(limiter Limiter, config RateLimitConfig, rateLimitKey string, reserved int, reservation limitter.Reservation, charged bool, w http.ResponseWriter, r *http.Request, next http.Handler) {
+	charge := newRequestCost(reserved)
+	r = r.WithContext(context.WithValue(r.Context(), requestCostKey{}, charge))
+
+	// The request context may already be cancelled once the response is written
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), 5*time.Second)
+	defer cancel()
+
+	if reservation != nil {
+		defer func() {
+			if p := recover(); p != nil {
+				reservation.Refund(ctx)
+				panic(p)
+			}
+		}()
+	}
+
+	rec := newResponseRecorder(w)
+	rec.beforeHeader = charge.readHeader
+	next.ServeHTTP(rec, r)
+	rec.flushHeaderHook()
+
+	counted := config.CountStatus == nil || config.CountStatus(rec.Status())
+	if reservation != nil {
+		if !counted || (config.RefundStatus != nil && config.RefundStatus(rec.Status())) {
+			reservation.Refund(ctx)
+			return
+		}
+		reservation.Commit(ctx)
+	}
+
+	actual := charge.Get()
+	costLimiter, hasCost := limiter.(CostLimiter)
+
+	if !charged {
+		if !counted || actual <= 0 {
+			return
+		}
+		// The request was served, so the charge can't be denied any more
+		if hasCost {
+			costLimiter.Adjust(ctx, rateLimitKey, config.WindowSize, actual)
+		} else {
+			limiter.Allow(ctx, rateLimitKey, config.enforcedLimit(), config.WindowSize)
+		}
+		return
+	}
+
+	if !counted {
+		actual = 0
+	}
+	if delta := actual - reserved; delta != 0 && hasCost {
+		costLimiter.Adjust(ctx, rateLimitKey, config.WindowSize, delta)
+	}
+}
+
 // defaultKeyFunc extracts IP address from request
 func defaultKeyFunc(r *http.Request) string {
 	// Check for X-Forwarded-For header (proxy/load balancer)
@@ -133,6 +520,28 @@ func defaultOnLimitExceeded(w http.ResponseWriter, r *http.Request, key string)
 	w.Write([]byte(response))
 }
 
+// writeBanHeaders sets the headers describing an active ban
+func writeBanHeaders(w http.ResponseWriter, ban *limitter.BanRecord) {
+	w.Header().Set("Retry-After", strconv.FormatInt(int64(time.Until(ban.ExpiresAt).Seconds()), 10))
+	w.Header().Set("X-RateLimit-Ban-Expires", strconv.FormatInt(ban.ExpiresAt.Unix(), 10))
+}
+
+// defaultOnBanned handles requests from temporarily banned clients
+func defaultOnBanned(w http.ResponseWriter, r *http.Request, ban *limitter.BanRecord) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusForbidden)
+
+	response := fmt.Sprintf(`{
+		"error": "Temporarily Banned",
+		"message": "Too many rate limit violations. Access is blocked until the ban expires.",
+		"code": %d,
+		"ban_expires": "%s",
+		"timestamp": "%s"
+	}`, http.StatusForbidden, ban.ExpiresAt.UTC().Format(time.RFC3339), time.Now().UTC().Format(time.RFC3339))
+
+	w.Write([]byte(response))
+}
+
 // Predefined key functions for common use cases
 
 // IPKeyFunc extracts client IP address
@@ -189,6 +598,47 @@ func CompositeKeyFunc(funcs ...func(*http.Request) string) func(*http.Request) s
 	}
 }
 
+// Predefined status functions for post-response accounting
+
+// CountClientErrors charges only 4xx responses
+func CountClientErrors(status int) bool {
+	return status >= 400 && status < 500
+}
+
+// CountServerErrors charges only 5xx responses
+func CountServerErrors(status int) bool {
+	return status >= 500 && status < 600
+}
+
+// CountErrors charges only 4xx and 5xx responses
+func CountErrors(status int) bool {
+	return status >= 400 && status < 600
+}
+
+// CountStatusCodes charges only responses with one of the given status codes
+func CountStatusCodes(codes ...int) func(int) bool {
+	return func(status int) bool {
+		for _, code := range codes {
+			if status == code {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Predefined status functions for refunds
+
+// RefundServerErrors refunds requests that failed with a 5xx response
+func RefundServerErrors(status int) bool {
+	return status >= 500 && status < 600
+}
+
+// RefundStatusCodes refunds requests with one of the given status codes
+func RefundStatusCodes(codes ...int) func(int) bool {
+	return CountStatusCodes(codes...)
+}
+
 // Predefined skip functions
 
 // SkipHealthChecks skips rate limiting for health check endpoints
@@ -0,0 +1,192 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"rate-limiter/internal/limitter"
+)
+
+// fakeCostLimiter is an in-memory CostLimiter charging requests only if
+// their cost fits
+type fakeCostLimiter struct {
+	mu   sync.Mutex
+	used map[string]int
+}
+
+func newFakeCostLimiter() *fakeCostLimiter {
+	return &fakeCostLimiter{used: make(map[string]int)}
+}
+
+func (l *fakeCostLimiter) Allow(ctx context.Context, key string, limit int, window time.Duration) (bool, int, time.Time, error) {
+	return l.AllowN(ctx, key, limit, window, 1)
+}
+
+func (l *fakeCostLimiter) AllowN(ctx context.Context, key string, limit int, window time.Duration, n int) (bool, int, time.Time, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	allowed := l.used[key]+n <= limit
+	if allowed {
+		l.used[key] += n
+	}
+	return allowed, max(limit-l.used[key], 0), time.Now().Add(window), nil
+}
+
+func (l *fakeCostLimiter) Adjust(ctx context.Context, key string, window time.Duration, delta int) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.used[key] = max(l.used[key]+delta, 0)
+	return nil
+}
+
+func (l *fakeCostLimiter) usage(key string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.used[key]
+}
+
+// fakePeekingLimiter is a fakeCostLimiter that can also peek
+type fakePeekingLimiter struct {
+	*fakeCostLimiter
+}
+
+func (l fakePeekingLimiter) Peek(ctx context.Context, key string, limit int, window time.Duration) (bool, int, time.Time, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.used[key] < limit, max(limit-l.used[key], 0), time.Now().Add(window), nil
+}
+
+// statusHandler responds with the status in the X-Status request header and
+// reports the cost in the X-Cost request header, if any
+var statusHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	if cost := r.Header.Get("X-Cost"); cost != "" {
+		w.Header().Set(CostHeader, cost)
+	}
+	status, _ := strconv.Atoi(r.Header.Get("X-Status"))
+	w.WriteHeader(status)
+})
+
+// statusRequest asks statusHandler for a status and an actual cost
+func statusRequest(status int, cost string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Status", strconv.Itoa(status))
+	if cost != "" {
+		req.Header.Set("X-Cost", cost)
+	}
+	return req
+}
+
+func TestCountStatus(t *testing.T) {
+	type step struct {
+		status int
+		cost   string
+		want   int
+		used   int
+	}
+	tests := []struct {
+		name  string
+		peek  bool
+		steps []step
+	}{
+		{
+			name: "peek charges counted responses",
+			peek: true,
+			steps: []step{
+				{status: http.StatusInternalServerError, want: http.StatusInternalServerError, used: 0},
+				{status: http.StatusOK, want: http.StatusOK, used: 3},
+			},
+		},
+		{
+			name: "peek rejects costs that don't fit",
+			peek: true,
+			steps: []step{
+				{status: http.StatusOK, want: http.StatusOK, used: 3},
+				{status: http.StatusOK, want: http.StatusTooManyRequests, used: 3},
+			},
+		},
+		{
+			name: "served requests are charged even over the limit",
+			peek: true,
+			steps: []step{
+				{status: http.StatusOK, cost: "6", want: http.StatusOK, used: 6},
+			},
+		},
+		{
+			name: "precharge is refunded for responses that don't count",
+			steps: []step{
+				{status: http.StatusInternalServerError, want: http.StatusInternalServerError, used: 0},
+				{status: http.StatusOK, want: http.StatusOK, used: 3},
+				{status: http.StatusOK, want: http.StatusTooManyRequests, used: 3},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			costLimiter := newFakeCostLimiter()
+			var limiter Limiter = costLimiter
+			if tt.peek {
+				limiter = fakePeekingLimiter{costLimiter}
+			}
+			handler := RateLimitMiddleware(limiter, RateLimitConfig{
+				MaxRequests: 4,
+				KeyFunc:     func(*http.Request) string { return "client" },
+				CostFunc:    func(*http.Request) int { return 3 },
+				CountStatus: func(status int) bool { return status < http.StatusInternalServerError },
+				AdjustCost:  true,
+			})(statusHandler)
+
+			for i, step := range tt.steps {
+				rec := httptest.NewRecorder()
+				handler.ServeHTTP(rec, statusRequest(step.status, step.cost))
+				if rec.Code != step.want {
+					t.Errorf("request %d: got status %d, want %d", i+1, rec.Code, step.want)
+				}
+				if got := costLimiter.usage("rate_limit:client"); got != step.used {
+					t.Errorf("request %d: got %d used, want %d", i+1, got, step.used)
+				}
+			}
+		})
+	}
+}
+
+// earlyLimiter rejects every request early
+type earlyLimiter struct {
+	calls int
+}
+
+func (l *earlyLimiter) Allow(ctx context.Context, key string, limit int, window time.Duration) (bool, int, time.Time, error) {
+	l.calls++
+	return false, 0, time.Now().Add(window), ErrRejectedEarly
+}
+
+func TestEarlyRejection(t *testing.T) {
+	limiter := &earlyLimiter{}
+	denials := limitter.NewDenialCache(time.Minute)
+	handler := RateLimitMiddleware(limiter, RateLimitConfig{
+		MaxRequests: 4,
+		KeyFunc:     func(*http.Request) string { return "client" },
+		Denials:     denials,
+	})(statusHandler)
+
+	for i := 0; i < 2; i++ {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, statusRequest(http.StatusOK, ""))
+		if rec.Code != http.StatusTooManyRequests {
+			t.Errorf("request %d: got status %d, want %d", i+1, rec.Code, http.StatusTooManyRequests)
+		}
+	}
+
+	// Early rejections are not cached, so every retry asks the limiter
+	if _, denied := denials.Denied("rate_limit:client"); denied {
+		t.Error("got the key cached as denied after an early rejection")
+	}
+	if limiter.calls != 2 {
+		t.Errorf("got %d limiter calls, want 2", limiter.calls)
+	}
+}
@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"net/http"
+)
+
+// responseRecorder wraps http.ResponseWriter to capture the status code and
+// the number of body bytes written
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	bytes       int64
+
+	// beforeHeader is called once with the response headers before they are sent
+	beforeHeader func(http.Header)
+}
+
+// newResponseRecorder creates a new response recorder around w
+func newResponseRecorder(w http.ResponseWriter) *responseRecorder {
+	return &responseRecorder{ResponseWriter: w}
+}
+
+// WriteHeader captures the status code before passing it on
+func (rw *responseRecorder) WriteHeader(status int) {
+	if rw.wroteHeader {
+		return
+	}
+	rw.flushHeaderHook()
+	rw.status = status
+	rw.wroteHeader = true
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+// Write counts the body bytes, writing an implicit 200 status if needed
+func (rw *responseRecorder) Write(b []byte) (int, error) {
+	if !rw.wroteHeader {
+		rw.WriteHeader(http.StatusOK)
+	}
+	n, err := rw.ResponseWriter.Write(b)
+	rw.bytes += int64(n)
+	return n, err
+}
+
+// Flush implements http.Flusher when the underlying writer supports it
+func (rw *responseRecorder) Flush() {
+	if f, ok := rw.ResponseWriter.(http.Flusher); ok {
+		if !rw.wroteHeader {
+			rw.WriteHeader(http.StatusOK)
+		}
+		f.Flush()
+	}
+}
+
+// Unwrap returns the underlying writer for http.ResponseController
+func (rw *responseRecorder) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// flushHeaderHook runs the beforeHeader hook if it has not run yet. It is also
+// called after the handler returns in case no header was written explicitly.
+func (rw *responseRecorder) flushHeaderHook() {
+	if rw.beforeHeader != nil {
+		rw.beforeHeader(rw.Header())
+		rw.beforeHeader = nil
+	}
+}
+
+// Status returns the response status code, defaulting to 200 if none was written
+func (rw *responseRecorder) Status() int {
+	if rw.status == 0 {
+		return http.StatusOK
+	}
+	return rw.status
+}
+
+// BytesWritten returns the number of body bytes written
+func (rw *responseRecorder) BytesWritten() int64 {
+	return rw.bytes
+}
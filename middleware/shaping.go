@@ -0,0 +1,181 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"rate-limiter/internal/limitter"
+)
+
+// ShapingConfig holds configuration for byte-rate shaping
+type ShapingConfig struct {
+	// BytesPerSecond is the transfer rate allowed per key
+	BytesPerSecond int64
+	// Burst is the number of bytes that may be sent at once (default BytesPerSecond)
+	Burst int64
+	// ShapeRequest throttles reading the request body
+	ShapeRequest bool
+	// ShapeResponse throttles writing the response body
+	ShapeResponse bool
+	// KeyFunc extracts the key from the request (e.g., IP address, API key)
+	KeyFunc func(*http.Request) string
+	// SkipFunc determines if shaping should be skipped for this request
+	SkipFunc func(*http.Request) bool
+	// Redis coordinates the rate across instances when set, otherwise each
+	// instance shapes with its own token buckets. Instances fall back to
+	// their own buckets while Redis is unavailable.
+	Redis limitter.RedisClient
+}
+
+// ShapingMiddleware throttles the transfer speed of request and response
+// bodies per key. Concurrent requests with the same key share one rate.
+func ShapingMiddleware(config ShapingConfig) func(http.Handler) http.Handler {
+	// Set default values
+	if config.BytesPerSecond == 0 {
+		config.BytesPerSecond = 1 << 20
+	}
+	if config.Burst == 0 {
+		config.Burst = config.BytesPerSecond
+	}
+	if !config.ShapeRequest && !config.ShapeResponse {
+		config.ShapeResponse = true
+	}
+	if config.KeyFunc == nil {
+		config.KeyFunc = defaultKeyFunc
+	}
+
+	registry := newByteLimiterRegistry(func(key string) limitter.ByteLimiter {
+		if config.Redis != nil {
+			return limitter.NewRedisByteLimiter(config.Redis, "rate_limit:shape:"+key, config.BytesPerSecond, config.Burst)
+		}
+		return limitter.NewTokenBucket(config.BytesPerSecond, config.Burst)
+	})
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if config.SkipFunc != nil && config.SkipFunc(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			key := config.KeyFunc(r)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			limiter, release := registry.acquire(key)
+			defer release()
+
+			if config.ShapeRequest && r.Body != nil {
+				r.Body = &shapedBody{
+					Reader: limitter.NewReader(r.Context(), r.Body, limiter),
+					body:   r.Body,
+				}
+			}
+
+			if config.ShapeResponse {
+				w = &shapedResponseWriter{
+					ResponseWriter: w,
+					writer:         limitter.NewWriter(r.Context(), w, limiter),
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// shapedBody throttles reads from a request body
+type shapedBody struct {
+	io.Reader
+	body io.Closer
+}
+
+func (b *shapedBody) Close() error {
+	return b.body.Close()
+}
+
+// shapedResponseWriter throttles writes to the response body
+type shapedResponseWriter struct {
+	http.ResponseWriter
+	writer io.Writer
+}
+
+func (w *shapedResponseWriter) Write(p []byte) (int, error) {
+	return w.writer.Write(p)
+}
+
+// Flush implements http.Flusher when the underlying writer supports it
+func (w *shapedResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the underlying writer for http.ResponseController
+func (w *shapedResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// byteLimiterIdleTimeout is how long an unused per-key limiter is kept
+const byteLimiterIdleTimeout = time.Minute
+
+// byteLimiterRegistry keeps one byte limiter per key and drops idle ones
+type byteLimiterRegistry struct {
+	mu        sync.Mutex
+	create    func(key string) limitter.ByteLimiter
+	limiters  map[string]*byteLimiterEntry
+	lastPrune time.Time
+}
+
+// byteLimiterEntry is a limiter and the number of requests using it
+type byteLimiterEntry struct {
+	limiter  limitter.ByteLimiter
+	refs     int
+	lastUsed time.Time
+}
+
+func newByteLimiterRegistry(create func(key string) limitter.ByteLimiter) *byteLimiterRegistry {
+	return &byteLimiterRegistry{
+		create:    create,
+		limiters:  make(map[string]*byteLimiterEntry),
+		lastPrune: time.Now(),
+	}
+}
+
+// acquire returns the limiter for key, creating it if needed. The limiter is
+// kept until release is called, so long transfers keep sharing one rate.
+func (reg *byteLimiterRegistry) acquire(key string) (limitter.ByteLimiter, func()) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(reg.lastPrune) > byteLimiterIdleTimeout {
+		for k, entry := range reg.limiters {
+			if entry.refs == 0 && now.Sub(entry.lastUsed) > byteLimiterIdleTimeout {
+				delete(reg.limiters, k)
+			}
+		}
+		reg.lastPrune = now
+	}
+
+	entry, ok := reg.limiters[key]
+	if !ok {
+		entry = &byteLimiterEntry{limiter: reg.create(key)}
+		reg.limiters[key] = entry
+	}
+	entry.refs++
+	entry.lastUsed = now
+
+	var once sync.Once
+	return entry.limiter, func() {
+		once.Do(func() {
+			reg.mu.Lock()
+			entry.refs--
+			entry.lastUsed = time.Now()
+			reg.mu.Unlock()
+		})
+	}
+}